@@ -0,0 +1,279 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"pip/fs"
+	"sort"
+	"strings"
+)
+
+var ErrVulnerable = errors.New("package version has known vulnerabilities")
+
+// Advisory is the subset of the OSV schema (https://ossf.github.io/osv-schema/)
+// that the audit needs.
+type Advisory struct {
+	ID       string             `json:"id"`
+	Summary  string             `json:"summary"`
+	Severity []AdvisorySeverity `json:"severity"`
+	Affected []AffectedPackage  `json:"affected"`
+
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type AdvisorySeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type AffectedPackage struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []AffectedRange `json:"ranges"`
+	Versions []string        `json:"versions"`
+}
+
+type AffectedRange struct {
+	Type   string       `json:"type"`
+	Events []RangeEvent `json:"events"`
+}
+
+type RangeEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// Finding is an advisory affecting an installed version. Severity is a level
+// such as HIGH, taken from the database or rated from the CVSS vector in
+// Score, and UNKNOWN when the advisory gives neither.
+type Finding struct {
+	Package  string
+	Version  string
+	ID       string
+	Summary  string
+	Severity string
+	Score    string
+	Fixed    []string
+}
+
+// advisoryEcosystems are the OSV ecosystems whose packages GOPI serves.
+var advisoryEcosystems = []string{"GOPI", "Go"}
+
+type AdvisoryDB struct {
+	advisories []Advisory
+}
+
+// ParseAdvisories accepts a single OSV object or an array of them.
+func ParseAdvisories(content string) ([]Advisory, error) {
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, "[") {
+		var list []Advisory
+		if err := json.Unmarshal([]byte(trimmed), &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	var adv Advisory
+	if err := json.Unmarshal([]byte(trimmed), &adv); err != nil {
+		return nil, err
+	}
+	return []Advisory{adv}, nil
+}
+
+// LoadAdvisories reads one JSON file, or every .json file below a directory.
+func LoadAdvisories(dir *fs.Dir, path string) (*AdvisoryDB, error) {
+	files, err := dir.ListFilesIn(path)
+	if err != nil {
+		return nil, err
+	}
+	db := &AdvisoryDB{}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".json") {
+			continue
+		}
+		content, err := dir.CatFile(file)
+		if err != nil {
+			return nil, err
+		}
+		advisories, err := ParseAdvisories(content)
+		if err != nil {
+			return nil, fmt.Errorf("advisory %s: %w", file, err)
+		}
+		db.advisories = append(db.advisories, advisories...)
+	}
+	return db, nil
+}
+
+// contains reports whether version falls in the range. Only SEMVER and
+// ECOSYSTEM ranges hold versions; GIT ranges hold commits, and OSV lists the
+// versions they cover in Versions. A bound that is not a valid version
+// matches every version rather than none.
+func (r AffectedRange) contains(version string) bool {
+	if !r.versioned() {
+		return false
+	}
+	for _, e := range r.Events {
+		for _, bound := range []string{e.Introduced, e.Fixed, e.LastAffected} {
+			if bound != "" && bound != "0" && !ValidVersion(bound) {
+				return true
+			}
+		}
+	}
+	introduced := ""
+	open := false
+	for _, e := range r.Events {
+		switch {
+		case e.Introduced != "":
+			introduced, open = e.Introduced, true
+		case e.Fixed != "" && open:
+			if atLeast(version, introduced) && CompareVersions(version, e.Fixed) < 0 {
+				return true
+			}
+			open = false
+		case e.LastAffected != "" && open:
+			if atLeast(version, introduced) && CompareVersions(version, e.LastAffected) <= 0 {
+				return true
+			}
+			open = false
+		}
+	}
+	return open && atLeast(version, introduced)
+}
+
+func (r AffectedRange) versioned() bool {
+	return r.Type == "SEMVER" || r.Type == "ECOSYSTEM"
+}
+
+func atLeast(version, introduced string) bool {
+	return introduced == "0" || CompareVersions(version, introduced) >= 0
+}
+
+func (r AffectedRange) fixed() []string {
+	result := make([]string, 0)
+	for _, e := range r.Events {
+		if e.Fixed != "" {
+			result = append(result, e.Fixed)
+		}
+	}
+	return result
+}
+
+// severity returns the level of the advisory and the CVSS vector it gives,
+// preferring a v3 vector since only those are rated.
+func (adv Advisory) severity() (string, string) {
+	score := ""
+	level := ""
+	for _, s := range adv.Severity {
+		if s.Type == "CVSS_V3" {
+			score, level = s.Score, cvssSeverity(s.Score)
+			break
+		}
+	}
+	if score == "" && len(adv.Severity) > 0 {
+		score = adv.Severity[0].Score
+	}
+	if adv.DatabaseSpecific.Severity != "" {
+		level = strings.ToUpper(adv.DatabaseSpecific.Severity)
+	}
+	if level == "" {
+		level = "UNKNOWN"
+	}
+	return level, score
+}
+
+// Lookup returns the advisories affecting a package version. Only entries of
+// the Go ecosystems count, and packages without a valid version are never
+// matched.
+func (db *AdvisoryDB) Lookup(pkgName, version string) []Finding {
+	result := make([]Finding, 0)
+	if !ValidVersion(version) {
+		return result
+	}
+	for _, adv := range db.advisories {
+		for _, affected := range adv.Affected {
+			if affected.Package.Name != pkgName || !Contains(advisoryEcosystems, affected.Package.Ecosystem) {
+				continue
+			}
+			hit := Contains(affected.Versions, version)
+			fixed := make([]string, 0)
+			for _, r := range affected.Ranges {
+				if !r.versioned() {
+					continue
+				}
+				if r.contains(version) {
+					hit = true
+				}
+				fixed = append(fixed, r.fixed()...)
+			}
+			if hit {
+				sort.Slice(fixed, func(i, j int) bool {
+					return CompareVersions(fixed[i], fixed[j]) < 0
+				})
+				severity, score := adv.severity()
+				result = append(result, Finding{
+					Package:  pkgName,
+					Version:  version,
+					ID:       adv.ID,
+					Summary:  adv.Summary,
+					Severity: severity,
+					Score:    score,
+					Fixed:    fixed,
+				})
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (pip *PIP) SetAdvisories(db *AdvisoryDB) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.advisories = db
+}
+
+// SetBlockVulnerable makes Install refuse versions with known advisories.
+func (pip *PIP) SetBlockVulnerable(block bool) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.blockVulnerable = block
+}
+
+func (pip *PIP) Audit() ([]Finding, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	result := make([]Finding, 0)
+	if pip.advisories == nil {
+		return result, nil
+	}
+	for _, pkgName := range pip.allInstalled {
+		dir, root := pip.pkgDir(pkgName)
+		meta, err := packageMetadata(pkgName, dir, root)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pip.advisories.Lookup(pkgName, meta.Version)...)
+	}
+	return result, nil
+}
+
+func (pip *PIP) checkAdvisories(meta *PackageMetadata) error {
+	if !pip.blockVulnerable || pip.advisories == nil {
+		return nil
+	}
+	findings := pip.advisories.Lookup(meta.Name, meta.Version)
+	if len(findings) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(findings))
+	for _, f := range findings {
+		ids = append(ids, f.ID)
+	}
+	return fmt.Errorf("%w: %s %s (%s)", ErrVulnerable, meta.Name, meta.Version, strings.Join(ids, ", "))
+}
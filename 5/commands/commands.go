@@ -4,60 +4,165 @@ package commands
 
 import (
 	"bufio"
+	"context"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"go/parser"
 	"go/token"
 	"pip/fs"
 	"strings"
-
-	"github.com/lithammer/fuzzysearch/fuzzy"
+	"sync"
+	"time"
 )
 
+// GOPI serves packages. The dir returned by Get belongs to the caller, which
+// closes it once done.
 type GOPI interface {
 	Get(string) (*fs.Dir, error)
 }
 
+// PIP is safe for concurrent use. Mutations of the install dir are also
+// serialized across processes with a file lock on the directory.
 type PIP struct {
-	installDir    *fs.Dir
-	gopi          GOPI
-	userInstalled []string
-	allInstalled  []string
-	onceInstalled map[string]bool
+	mu              sync.RWMutex
+	installDir      *fs.Dir
+	gopi            GOPI
+	registry        ContextGOPI
+	userInstalled   []string
+	allInstalled    []string
+	licensePolicy   LicensePolicy
+	hooksEnabled    bool
+	hookTimeout     time.Duration
+	signingKey      ed25519.PrivateKey
+	trustedKeys     []ed25519.PublicKey
+	allowUnsigned   map[string]bool
+	advisories      *AdvisoryDB
+	blockVulnerable bool
+	observersMu     sync.Mutex
+	observers       []Observer
+	hashes          map[string]map[string]string
+	editable        map[string]*fs.Dir
+	groups          map[string][]string
+	history         []*Operation
+	historyLimit    int
+	pending         *Operation
+	searchCache     searchCache
 }
 
 func NewPIP(dir *fs.Dir, gopi GOPI) *PIP {
 	return &PIP{
 		installDir:    dir,
 		gopi:          gopi,
+		registry:      WithContext(gopi),
 		userInstalled: nil,
 		allInstalled:  nil,
-		onceInstalled: make(map[string]bool),
+		hooksEnabled:  true,
+		hookTimeout:   DefaultHookTimeout,
+		allowUnsigned: make(map[string]bool),
+		hashes:        make(map[string]map[string]string),
+		editable:      make(map[string]*fs.Dir),
+		groups:        make(map[string][]string),
+		historyLimit:  DefaultHistoryLimit,
 	}
 }
 
-func parseRequirementsTXT(reqTXTContent string) []string {
-	result := make([]string, 0)
+// lockPoll is how often a lock that is held is tried again while waiting
+// for it can be cancelled.
+const lockPoll = 10 * time.Millisecond
+
+// acquire takes a lock, giving up once ctx is done. Mutexes cannot wait on a
+// channel, so a cancellable wait tries the lock every lockPoll.
+func acquire(ctx context.Context, tryLock func() bool, lock func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+	for !tryLock() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+	return nil
+}
+
+// lock takes the PIP for a mutation, together with the file lock on the
+// install dir, or fails with ctx.Err() if ctx is done before both are held.
+func (pip *PIP) lock(ctx context.Context) (func(), error) {
+	if err := acquire(ctx, pip.mu.TryLock, pip.mu.Lock); err != nil {
+		return nil, err
+	}
+	unlockDir, err := pip.installDir.LockContext(ctx)
+	if err != nil {
+		pip.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockDir()
+		pip.mu.Unlock()
+	}, nil
+}
+
+func parseRequirementsTXT(pkgName, reqTXTContent string) ([]Requirement, error) {
+	result := make([]Requirement, 0)
 	scanner := bufio.NewScanner(strings.NewReader(reqTXTContent))
+	lineNo := 0
 	for scanner.Scan() {
-		result = append(result, scanner.Text())
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		req, err := ParseRequirement(line)
+		if err != nil {
+			return nil, &ErrInvalidRequirements{Pkg: pkgName, Line: lineNo, Err: err}
+		}
+		result = append(result, req)
 	}
 	if scanner.Err() != nil {
-		panic(scanner.Err())
+		return nil, &ErrInvalidRequirements{Pkg: pkgName, Err: scanner.Err()}
+	}
+	return result, nil
+}
+
+func (pip *PIP) requirements(ctx context.Context, pkgName string) ([]Requirement, error) {
+	pkDir, ok := pip.editable[pkgName]
+	if !ok {
+		var err error
+		pkDir, err = pip.registry.GetContext(ctx, pkgName)
+		if err != nil {
+			return nil, err
+		}
+		defer pkDir.Close()
+	}
+	reqs, err := pkDir.CatFile("requirements.txt")
+	if err != nil {
+		return nil, &ErrInvalidRequirements{Pkg: pkgName, Err: err}
+	}
+	return parseRequirementsTXT(pkgName, reqs)
+}
+
+func requirementNames(reqs []Requirement) []string {
+	result := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		result = append(result, req.Name)
 	}
 	return result
 }
 
 func (pip *PIP) DirectDeps(pkgName string) ([]string, error) {
-	pkDir, err := pip.gopi.Get(pkgName)
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	reqs, err := pip.requirements(context.Background(), pkgName)
 	if err != nil {
 		return nil, err
 	}
-	reqs, err := pkDir.CatFile("requirements.txt")
-	if err != nil {
-		return nil, errors.New("invalid project dependencies")
-	}
-	return parseRequirementsTXT(reqs), nil
+	return requirementNames(reqs), nil
 }
 
 func AddAllToMap(m map[string]bool, slice []string) {
@@ -75,14 +180,19 @@ func MapToSlice(m map[string]bool) []string {
 }
 
 func (pip *PIP) AllDeps(pkgName string) ([]string, error) {
+	return pip.AllDepsContext(context.Background(), pkgName)
+}
+
+func (pip *PIP) allDeps(ctx context.Context, pkgName string) ([]string, error) {
 	result := make(map[string]bool)
-	direct, err := pip.DirectDeps(pkgName)
+	reqs, err := pip.requirements(ctx, pkgName)
 	if err != nil {
 		return nil, err
 	}
+	direct := requirementNames(reqs)
 	AddAllToMap(result, direct)
 	for _, dep := range direct {
-		dep_deps, err := pip.AllDeps(dep)
+		dep_deps, err := pip.allDeps(ctx, dep)
 		if err != nil {
 			return nil, err
 		}
@@ -101,72 +211,146 @@ func Contains(list []string, elem string) bool {
 }
 
 func (pip *PIP) CopyFromGopi(pkgName string) error {
-	pip.onceInstalled[pkgName] = true
-	if !Contains(pip.allInstalled, pkgName) {
-		pip.allInstalled = append(pip.allInstalled, pkgName)
-	} else {
+	unlock, err := pip.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpInstall, []string{pkgName}, func() error {
+		return pip.copyFromGopi(context.Background(), pkgName)
+	})
+}
+
+func (pip *PIP) copyFromGopi(ctx context.Context, pkgName string) error {
+	if Contains(pip.allInstalled, pkgName) {
+		pip.emit(PackageSkipped{Package: pkgName})
 		return nil
 	}
-	dl, err := pip.gopi.Get(pkgName)
+	start := time.Now()
+	dl, err := pip.registry.GetContext(ctx, pkgName)
 	if err != nil {
 		return err
 	}
-	err = pip.installDir.Mount(pkgName, dl)
+	defer dl.Close()
+	size, _ := dl.Size("")
+	pip.emit(PackageFetched{Package: pkgName, Bytes: size, Duration: time.Since(start)})
+	if err := pip.checkSignature(pkgName, dl); err != nil {
+		return err
+	}
+	meta, err := packageMetadata(pkgName, dl, "")
 	if err != nil {
 		return err
 	}
+	// the package is staged in the install dir so its pre-install hook runs
+	// confined there, and only takes its name once the hook passed
+	staging := "." + pkgName + ".staging"
+	err = pip.installDir.MountContext(ctx, staging, dl)
+	if err != nil {
+		return err
+	}
+	err = pip.runHook(ctx, pkgName, "pre-install", meta.Hooks.PreInstall, pip.installDir, staging)
+	if err == nil {
+		err = pip.installDir.Move(staging, pkgName)
+	}
+	if err != nil {
+		if rmErr := pip.installDir.Remove(staging); rmErr != nil {
+			return errors.Join(err, rmErr)
+		}
+		return err
+	}
+	err = pip.runHook(ctx, pkgName, "post-install", meta.Hooks.PostInstall, pip.installDir, pkgName)
+	if err != nil {
+		if rmErr := pip.installDir.Remove(pkgName); rmErr != nil {
+			return errors.Join(err, rmErr)
+		}
+		return err
+	}
+	pip.allInstalled = append(pip.allInstalled, pkgName)
+	pip.noteInstalled(pkgName, meta.Version)
+	if err := pip.recordHashes(pkgName); err != nil {
+		return err
+	}
+	pip.emit(PackageMounted{Package: pkgName})
 	return nil
 }
 
-func (pip *PIP) Install(pkgNames ...string) error {
-	for _, pkgName := range pkgNames {
-		_, err := pip.AllDeps(pkgName)
-		if err != nil {
-			return err
+// rollback removes every package mounted since the given snapshot of the
+// installed lists was taken.
+func (pip *PIP) rollback(userInstalled, allInstalled []string) error {
+	var errs []error
+	for _, pkgName := range pip.allInstalled {
+		if !Contains(allInstalled, pkgName) {
+			delete(pip.hashes, pkgName)
+			pip.noteRemoved(pkgName, "")
+			errs = append(errs, pip.installDir.Remove(pkgName))
 		}
 	}
-	for _, pkgName := range pkgNames {
-		allDeps, err := pip.AllDeps(pkgName)
-		if err != nil {
-			return err
-		}
-		err = pip.CopyFromGopi(pkgName)
+	pip.userInstalled = userInstalled
+	pip.allInstalled = allInstalled
+	return errors.Join(errs...)
+}
+
+func (pip *PIP) Install(pkgSpecs ...string) error {
+	return pip.InstallContext(context.Background(), pkgSpecs...)
+}
+
+func (pip *PIP) install(ctx context.Context, specs []Requirement) error {
+	pip.emit(ResolveStarted{Packages: requirementNames(specs)})
+	for _, pkgName := range requirementNames(specs) {
+		_, err := pip.allDeps(ctx, pkgName)
 		if err != nil {
 			return err
 		}
-		for _, v := range allDeps {
-			err := pip.CopyFromGopi(v)
-			if err != nil {
-				return err
-			}
-		}
-		if !Contains(pip.userInstalled, pkgName) {
-			pip.userInstalled = append(pip.userInstalled, pkgName)
+	}
+	if err := pip.resolve(ctx, specs); err != nil {
+		return err
+	}
+	userInstalled := append([]string{}, pip.userInstalled...)
+	allInstalled := append([]string{}, pip.allInstalled...)
+	for _, pkgName := range requirementNames(specs) {
+		if err := pip.installOne(ctx, pkgName); err != nil {
+			return errors.Join(err, pip.rollback(userInstalled, allInstalled))
 		}
 	}
 	return nil
 }
 
-func (pip *PIP) InstallR(dir *fs.Dir, reqFile string) error {
-	reqs, err := dir.CatFile(reqFile)
+func (pip *PIP) installOne(ctx context.Context, pkgName string) error {
+	allDeps, err := pip.allDeps(ctx, pkgName)
 	if err != nil {
 		return err
 	}
-	allPkgs := parseRequirementsTXT(reqs)
-	err = pip.Install(allPkgs...)
+	err = pip.copyFromGopi(ctx, pkgName)
 	if err != nil {
 		return err
 	}
+	for _, v := range allDeps {
+		err := pip.copyFromGopi(ctx, v)
+		if err != nil {
+			return err
+		}
+	}
+	if !Contains(pip.userInstalled, pkgName) {
+		pip.userInstalled = append(pip.userInstalled, pkgName)
+	}
 	return nil
 }
 
+func (pip *PIP) InstallR(dir *fs.Dir, reqFile string) error {
+	return pip.InstallRContext(context.Background(), dir, reqFile)
+}
+
 func (pip *PIP) AllUserInstalledPackages() []string {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
 	result := make([]string, 0, len(pip.userInstalled))
 	result = append(result, pip.userInstalled...)
 	return result
 }
 
 func (pip *PIP) AllInstalledPackages() []string {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
 	result := make([]string, 0, len(pip.allInstalled))
 	result = append(result, pip.allInstalled...)
 	return result
@@ -180,13 +364,24 @@ func RemoveFromList(list []string, elem string) []string {
 	}
 	return list
 }
-func (pip *PIP) AllNeededDepsForCurPkgs() []string {
+func (pip *PIP) AllNeededDepsForCurPkgs() ([]string, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	return pip.allNeededDeps(context.Background())
+}
+
+func (pip *PIP) allNeededDeps(ctx context.Context) ([]string, error) {
+	return pip.neededDeps(ctx, pip.userInstalled)
+}
+
+// neededDeps returns the given packages together with all their dependencies.
+func (pip *PIP) neededDeps(ctx context.Context, pkgNames []string) ([]string, error) {
 	result := make([]string, 0)
-	result = append(result, pip.AllUserInstalledPackages()...)
-	for _, pkg := range pip.AllUserInstalledPackages() {
-		allDeps, err := pip.AllDeps(pkg)
+	result = append(result, pkgNames...)
+	for _, pkg := range pkgNames {
+		allDeps, err := pip.allDeps(ctx, pkg)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		for _, dep := range allDeps {
 			if !Contains(result, dep) {
@@ -194,115 +389,219 @@ func (pip *PIP) AllNeededDepsForCurPkgs() []string {
 			}
 		}
 	}
-	return result
+	return result, nil
 }
 
-func (pip *PIP) Fix() {
-	err := pip.CheckAndRemoveDanglings()
+func (pip *PIP) Fix() error {
+	return pip.FixContext(context.Background())
+}
+
+func (pip *PIP) fix(ctx context.Context) error {
+	err := pip.removeDanglings(ctx)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	if pip.Check() == nil {
-		return
+	if err := pip.reinstallCorrupted(ctx); err != nil {
+		return err
+	}
+	if pip.check(ctx) == nil {
+		return nil
+	}
+	needed, err := pip.allNeededDeps(ctx)
+	if err != nil {
+		return err
 	}
-	for _, pkg := range pip.AllNeededDepsForCurPkgs() {
-		if Contains(pip.AllInstalledPackages(), pkg) {
+	for _, pkg := range needed {
+		if Contains(pip.allInstalled, pkg) {
 			continue
 		}
-		allDeps, err := pip.AllDeps(pkg)
+		allDeps, err := pip.allDeps(ctx, pkg)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		err = pip.CopyFromGopi(pkg)
+		err = pip.copyFromGopi(ctx, pkg)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		for _, v := range allDeps {
-			err := pip.CopyFromGopi(v)
+			err := pip.copyFromGopi(ctx, v)
 			if err != nil {
-				panic(err)
+				return err
 			}
 		}
 	}
+	return nil
 }
 
-func (pip *PIP) FindDanglings() []string {
-	allNeededDeps := pip.AllNeededDepsForCurPkgs()
+// FindDanglings returns installed packages nothing depends on. Given groups,
+// it returns what would be dangling if only those groups were kept.
+func (pip *PIP) FindDanglings(groups ...string) ([]string, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	if len(groups) == 0 {
+		return pip.findDanglings(context.Background(), pip.userInstalled)
+	}
+	members, err := pip.groupMembers(groups)
+	if err != nil {
+		return nil, err
+	}
+	return pip.findDanglings(context.Background(), members)
+}
+
+func (pip *PIP) findDanglings(ctx context.Context, keep []string) ([]string, error) {
+	allNeededDeps, err := pip.neededDeps(ctx, keep)
+	if err != nil {
+		return nil, err
+	}
 	danglingPackages := make([]string, 0)
 
-	for _, installedPkg := range pip.AllInstalledPackages() {
+	for _, installedPkg := range pip.allInstalled {
 		if !Contains(allNeededDeps, installedPkg) && !Contains(danglingPackages, installedPkg) {
 			danglingPackages = append(danglingPackages, installedPkg)
 		}
 	}
-	return danglingPackages
+	return danglingPackages, nil
 }
 
 func (pip *PIP) CheckAndRemoveDanglings() error {
-	return pip.UninstallForce(pip.FindDanglings()...)
+	unlock, err := pip.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpUninstall, nil, func() error {
+		return pip.removeDanglings(context.Background())
+	})
+}
+
+func (pip *PIP) removeDanglings(ctx context.Context) error {
+	danglings, err := pip.findDanglings(ctx, pip.userInstalled)
+	if err != nil {
+		return err
+	}
+	if err := pip.uninstallForce(ctx, danglings...); err != nil {
+		return err
+	}
+	for _, pkgName := range danglings {
+		pip.emit(DanglingCleaned{Package: pkgName})
+	}
+	return nil
 }
 
 func (pip *PIP) Uninstall(pkgNamesToRemove ...string) error {
+	return pip.UninstallContext(context.Background(), pkgNamesToRemove...)
+}
+
+func (pip *PIP) uninstall(ctx context.Context, pkgNamesToRemove ...string) error {
 	for _, pkgName := range pkgNamesToRemove {
-		if !Contains(pip.AllUserInstalledPackages(), pkgName) {
+		if !Contains(pip.userInstalled, pkgName) {
 			return fmt.Errorf(
-				"pkg %s is not installed explicitly, so it cannot removed",
-				pkgName,
+				"pkg %s is not installed explicitly, so it cannot removed: %w",
+				pkgName, ErrNotInstalled,
 			)
 		}
 	}
 	// Check if any other user-installed packages depend on the packages to be removed
 	for _, pkgNameToRemove := range pkgNamesToRemove {
 		neededByOtherUserPkgs := make([]string, 0)
-		for _, userPkg := range pip.AllUserInstalledPackages() {
+		for _, userPkg := range pip.userInstalled {
 			if userPkg == pkgNameToRemove { // Skip checking against itself
 				continue
 			}
-			userPkgDeps, err := pip.AllDeps(userPkg)
+			userPkgDeps, err := pip.allDeps(ctx, userPkg)
 			if err != nil {
-				panic(err) // This should ideally be handled more gracefully
+				return err
 			}
 			if Contains(userPkgDeps, pkgNameToRemove) {
 				neededByOtherUserPkgs = append(neededByOtherUserPkgs, userPkg)
 			}
 		}
 		if len(neededByOtherUserPkgs) > 0 {
-			return fmt.Errorf("cannot remove %s because pkgs %v need this", pkgNameToRemove, neededByOtherUserPkgs)
+			return &ErrRequiredBy{Pkg: pkgNameToRemove, Dependents: neededByOtherUserPkgs}
 		}
 	}
 	// If all checks pass, proceed with forced uninstallation
-	if err := pip.UninstallForce(pkgNamesToRemove...); err != nil {
+	if err := pip.uninstallForce(ctx, pkgNamesToRemove...); err != nil {
 		return err
 	}
 	// After uninstallation, check for and remove any newly created dangling dependencies
-	err := pip.CheckAndRemoveDanglings()
-	return err
+	return pip.removeDanglings(ctx)
 }
 
 func (pip *PIP) UninstallForce(pkgNames ...string) error {
+	unlock, err := pip.lock(context.Background())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpUninstall, pkgNames, func() error {
+		return pip.uninstallForce(context.Background(), pkgNames...)
+	})
+}
+
+func (pip *PIP) uninstallForce(ctx context.Context, pkgNames ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	for _, pkgName := range pkgNames {
-		if !Contains(pip.AllInstalledPackages(), pkgName) {
-			return fmt.Errorf("pkg %s is not installed", pkgName)
+		if !Contains(pip.allInstalled, pkgName) {
+			return fmt.Errorf("pkg %s: %w", pkgName, ErrNotInstalled)
 		}
 	}
 	for _, pkgName := range pkgNames {
+		// a package with a broken manifest can still be force removed
+		if _, ok := pip.editable[pkgName]; ok {
+			continue
+		}
+		meta, err := packageMetadata(pkgName, pip.installDir, pkgName)
+		if err != nil {
+			continue
+		}
+		err = pip.runHook(ctx, pkgName, "pre-uninstall", meta.Hooks.PreUninstall, pip.installDir, pkgName)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pkgName := range pkgNames {
+		version := ""
+		dir, root := pip.pkgDir(pkgName)
+		if meta, err := packageMetadata(pkgName, dir, root); err == nil {
+			version = meta.Version
+		}
 		pip.userInstalled = RemoveFromList(pip.userInstalled, pkgName)
 		pip.allInstalled = RemoveFromList(pip.allInstalled, pkgName)
+		delete(pip.hashes, pkgName)
+		delete(pip.editable, pkgName)
+		pip.removeFromGroups(pkgName)
 		if err := pip.installDir.Remove(pkgName); err != nil {
 			return err
 		}
+		pip.noteRemoved(pkgName, version)
+		pip.emit(PackageRemoved{Package: pkgName})
 	}
 	return nil
 }
 
 func (pip *PIP) Check() error {
-	needed := pip.AllNeededDepsForCurPkgs()
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	return pip.check(context.Background())
+}
+
+func (pip *PIP) check(ctx context.Context) error {
+	if err := pip.checkEditable(); err != nil {
+		return err
+	}
+	needed, err := pip.allNeededDeps(ctx)
+	if err != nil {
+		return err
+	}
 	for _, need := range needed {
-		if !Contains(pip.AllInstalledPackages(), need) {
-			return fmt.Errorf("%s should be installed but its not", need)
+		if !Contains(pip.allInstalled, need) {
+			return fmt.Errorf("%s should be installed but its not: %w", need, ErrNotInstalled)
 		}
 	}
-	return nil
+	return pip.checkConstraints()
 }
 
 func StdLib(lib string) bool {
@@ -358,6 +657,8 @@ func StdLib(lib string) bool {
 }
 
 func (pip *PIP) ImportCheck(src string) error {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
 	if err != nil {
@@ -366,20 +667,8 @@ func (pip *PIP) ImportCheck(src string) error {
 	for _, imp := range f.Imports {
 		impStr := strings.Trim(imp.Path.Value, "\"")
 		if !Contains(pip.allInstalled, impStr) && !StdLib(impStr) {
-			return errors.New("unsatisfied import" + impStr)
+			return fmt.Errorf("unsatisfied import %s: %w", impStr, ErrNotInstalled)
 		}
 	}
 	return nil
 }
-
-func (pip *PIP) OnceInstalledPackages() []string {
-	res := make([]string, 0, 20)
-	for k := range pip.onceInstalled {
-		res = append(res, k)
-	}
-	return res
-}
-
-func (pip *PIP) LocalSearch(term string) []string {
-	return fuzzy.Find(term, pip.OnceInstalledPackages())
-}
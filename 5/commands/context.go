@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"pip/fs"
+)
+
+type ContextGOPI interface {
+	GetContext(ctx context.Context, pkgName string) (*fs.Dir, error)
+}
+
+type contextAdapter struct {
+	GOPI
+}
+
+// WithContext adapts a context-less GOPI. A cancelled call returns at once
+// while the underlying Get finishes in the background and its result is
+// closed.
+func WithContext(gopi GOPI) ContextGOPI {
+	if cg, ok := gopi.(ContextGOPI); ok {
+		return cg
+	}
+	return contextAdapter{gopi}
+}
+
+func (a contextAdapter) GetContext(ctx context.Context, pkgName string) (*fs.Dir, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		dir *fs.Dir
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		dir, err := a.Get(pkgName)
+		done <- result{dir, err}
+	}()
+	select {
+	case r := <-done:
+		return r.dir, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.dir != nil {
+				r.dir.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+type backgroundGOPI struct {
+	ContextGOPI
+}
+
+func (b backgroundGOPI) Get(pkgName string) (*fs.Dir, error) {
+	return b.GetContext(context.Background(), pkgName)
+}
+
+func NewPIPContext(dir *fs.Dir, registry ContextGOPI) *PIP {
+	gopi, ok := registry.(GOPI)
+	if !ok {
+		gopi = backgroundGOPI{registry}
+	}
+	return NewPIP(dir, gopi)
+}
+
+func (pip *PIP) AllDepsContext(ctx context.Context, pkgName string) ([]string, error) {
+	if err := acquire(ctx, pip.mu.TryRLock, pip.mu.RLock); err != nil {
+		return nil, err
+	}
+	defer pip.mu.RUnlock()
+	return pip.allDeps(ctx, pkgName)
+}
+
+func (pip *PIP) InstallContext(ctx context.Context, pkgSpecs ...string) error {
+	specs := make([]Requirement, 0, len(pkgSpecs))
+	for _, spec := range pkgSpecs {
+		req, err := ParseRequirement(spec)
+		if err != nil {
+			return err
+		}
+		specs = append(specs, req)
+	}
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpInstall, requirementNames(specs), func() error {
+		if err := pip.install(ctx, specs); err != nil {
+			return err
+		}
+		pip.addToGroup(MainGroup, requirementNames(specs))
+		return nil
+	})
+}
+
+func (pip *PIP) InstallRContext(ctx context.Context, dir *fs.Dir, reqFile string) error {
+	reqs, err := dir.CatFile(reqFile)
+	if err != nil {
+		return err
+	}
+	allPkgs, err := parseRequirementsTXT(reqFile, reqs)
+	if err != nil {
+		return err
+	}
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpInstall, requirementNames(allPkgs), func() error {
+		if err := pip.install(ctx, allPkgs); err != nil {
+			return err
+		}
+		pip.addToGroup(MainGroup, requirementNames(allPkgs))
+		return nil
+	})
+}
+
+func (pip *PIP) FixContext(ctx context.Context) error {
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpFix, nil, func() error {
+		return pip.fix(ctx)
+	})
+}
+
+func (pip *PIP) UninstallContext(ctx context.Context, pkgNamesToRemove ...string) error {
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpUninstall, pkgNamesToRemove, func() error {
+		return pip.uninstall(ctx, pkgNamesToRemove...)
+	})
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"math"
+	"strings"
+)
+
+var cvssImpact = map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+
+// cvssWeights holds the weights of the CVSS v3 base metrics. The weights of
+// PR change when the scope does, see cvssBaseScore.
+var cvssWeights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"S":  {"U": 0, "C": 0},
+	"C":  cvssImpact,
+	"I":  cvssImpact,
+	"A":  cvssImpact,
+}
+
+// cvssBaseScore computes the base score of a CVSS v3 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func cvssBaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if parts[0] != "CVSS:3.0" && parts[0] != "CVSS:3.1" {
+		return 0, false
+	}
+	metrics := make(map[string]string)
+	for _, part := range parts[1:] {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok {
+			return 0, false
+		}
+		metrics[key] = value
+	}
+	w := make(map[string]float64)
+	for key, values := range cvssWeights {
+		weight, ok := values[metrics[key]]
+		if !ok {
+			return 0, false
+		}
+		w[key] = weight
+	}
+	changed := metrics["S"] == "C"
+	if changed {
+		switch metrics["PR"] {
+		case "L":
+			w["PR"] = 0.68
+		case "H":
+			w["PR"] = 0.5
+		}
+	}
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// roundUp rounds up to one decimal the way CVSS 3.1 does, so that float
+// errors do not push a score up a step.
+func roundUp(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+// cvssSeverity rates a CVSS v3 vector by its base score. It returns "" for
+// vectors it cannot score.
+func cvssSeverity(vector string) string {
+	score, ok := cvssBaseScore(vector)
+	switch {
+	case !ok:
+		return ""
+	case score == 0:
+		return "NONE"
+	case score < 4:
+		return "LOW"
+	case score < 7:
+		return "MEDIUM"
+	case score < 9:
+		return "HIGH"
+	}
+	return "CRITICAL"
+}
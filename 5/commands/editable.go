@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pip/fs"
+)
+
+// pkgDir returns where the files of an installed package can be read: the
+// linked project for editable installs, the package's directory otherwise.
+func (pip *PIP) pkgDir(pkgName string) (*fs.Dir, string) {
+	if src, ok := pip.editable[pkgName]; ok {
+		return src, ""
+	}
+	return pip.installDir, pkgName
+}
+
+func (pip *PIP) IsEditable(pkgName string) bool {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	_, ok := pip.editable[pkgName]
+	return ok
+}
+
+func (pip *PIP) InstallEditable(dir *fs.Dir) error {
+	return pip.InstallEditableContext(context.Background(), dir)
+}
+
+// InstallEditableContext links a local project into the install dir instead
+// of copying it, so edits to the project are visible at once. Its
+// dependencies are installed from GOPI. Hooks of the project are not run.
+func (pip *PIP) InstallEditableContext(ctx context.Context, dir *fs.Dir) error {
+	meta, err := ReadMetadata(dir)
+	if errors.Is(err, ErrNoManifest) {
+		return fmt.Errorf("editable install needs a %s with the package name: %w", ManifestFile, err)
+	}
+	if err != nil {
+		return err
+	}
+	content, err := dir.CatFile("requirements.txt")
+	if err != nil {
+		return &ErrInvalidRequirements{Pkg: meta.Name, Err: err}
+	}
+	reqs, err := parseRequirementsTXT(meta.Name, content)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, ok := pip.editable[meta.Name]; !ok && Contains(pip.allInstalled, meta.Name) {
+		return fmt.Errorf("pkg %s is already installed from GOPI", meta.Name)
+	}
+	return pip.record(OpInstall, []string{meta.Name}, func() error {
+		previous, relinked := pip.editable[meta.Name]
+		pip.editable[meta.Name] = dir
+		if err := pip.installEditable(ctx, meta.Name, reqs, previous); err != nil {
+			if relinked {
+				pip.editable[meta.Name] = previous
+			} else {
+				delete(pip.editable, meta.Name)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// installEditable installs the dependencies of an editable package and links
+// it. previous is the project the package was linked to before, if any.
+func (pip *PIP) installEditable(ctx context.Context, pkgName string, reqs []Requirement, previous *fs.Dir) error {
+	if err := pip.resolve(ctx, reqs); err != nil {
+		return err
+	}
+	userInstalled := append([]string{}, pip.userInstalled...)
+	allInstalled := append([]string{}, pip.allInstalled...)
+	for _, dep := range requirementNames(reqs) {
+		allDeps, err := pip.allDeps(ctx, dep)
+		if err == nil {
+			err = pip.copyFromGopi(ctx, dep)
+		}
+		for _, v := range allDeps {
+			if err == nil {
+				err = pip.copyFromGopi(ctx, v)
+			}
+		}
+		if err != nil {
+			return errors.Join(err, pip.rollback(userInstalled, allInstalled))
+		}
+	}
+	if Contains(pip.allInstalled, pkgName) {
+		// re-linking an editable package refreshes its dependencies and points
+		// the link at the new project
+		if previous == nil || previous.Path() == pip.editable[pkgName].Path() {
+			return nil
+		}
+		if err := pip.relink(pkgName, previous); err != nil {
+			return errors.Join(err, pip.rollback(userInstalled, allInstalled))
+		}
+		pip.emit(PackageMounted{Package: pkgName})
+		return nil
+	}
+	if err := pip.installDir.Link(pkgName, pip.editable[pkgName]); err != nil {
+		return errors.Join(err, pip.rollback(userInstalled, allInstalled))
+	}
+	version := ""
+	if meta, err := packageMetadata(pkgName, pip.editable[pkgName], ""); err == nil {
+		version = meta.Version
+	}
+	pip.allInstalled = append(pip.allInstalled, pkgName)
+	pip.noteInstalled(pkgName, version)
+	pip.userInstalled = append(pip.userInstalled, pkgName)
+	pip.addToGroup(MainGroup, []string{pkgName})
+	pip.emit(PackageMounted{Package: pkgName})
+	return nil
+}
+
+// relink points the link of an editable package at its new project. When that
+// fails the link to previous is put back.
+func (pip *PIP) relink(pkgName string, previous *fs.Dir) error {
+	if err := pip.installDir.Remove(pkgName); err != nil {
+		return err
+	}
+	if err := pip.installDir.Link(pkgName, pip.editable[pkgName]); err != nil {
+		return errors.Join(err, pip.installDir.Link(pkgName, previous))
+	}
+	return nil
+}
+
+// checkEditable reports editable packages whose project has disappeared.
+func (pip *PIP) checkEditable() error {
+	for pkgName, src := range pip.editable {
+		if _, err := src.ListFilesIn(""); err != nil {
+			return fmt.Errorf("editable pkg %s: project directory is gone: %w", pkgName, ErrNotInstalled)
+		}
+	}
+	return nil
+}
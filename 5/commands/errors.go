@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrPackageNotFound should be wrapped by GOPI implementations when they
+	// do not serve the requested package.
+	ErrPackageNotFound = errors.New("package not found")
+	ErrNotInstalled    = errors.New("package is not installed")
+)
+
+type ErrRequiredBy struct {
+	Pkg        string
+	Dependents []string
+}
+
+func (e *ErrRequiredBy) Error() string {
+	return fmt.Sprintf("cannot remove %s because pkgs %v need this", e.Pkg, e.Dependents)
+}
+
+// ErrInvalidRequirements reports an unreadable requirements file or a bad
+// line in it. Line is 1-based and zero when the file itself is the problem.
+type ErrInvalidRequirements struct {
+	Pkg  string
+	Line int
+	Err  error
+}
+
+func (e *ErrInvalidRequirements) Error() string {
+	var sb strings.Builder
+	sb.WriteString("invalid project dependencies of " + e.Pkg)
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, " at line %d", e.Line)
+	}
+	if e.Err != nil {
+		sb.WriteString(": " + e.Err.Error())
+	}
+	return sb.String()
+}
+
+func (e *ErrInvalidRequirements) Unwrap() error {
+	return e.Err
+}
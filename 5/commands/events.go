@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Event interface {
+	isEvent()
+}
+
+type ResolveStarted struct {
+	Packages []string
+}
+
+type PackageFetched struct {
+	Package  string
+	Bytes    int64
+	Duration time.Duration
+}
+
+type PackageMounted struct {
+	Package string
+}
+
+type PackageSkipped struct {
+	Package string
+}
+
+type PackageRemoved struct {
+	Package string
+}
+
+type DanglingCleaned struct {
+	Package string
+}
+
+func (ResolveStarted) isEvent()  {}
+func (PackageFetched) isEvent()  {}
+func (PackageMounted) isEvent()  {}
+func (PackageSkipped) isEvent()  {}
+func (PackageRemoved) isEvent()  {}
+func (DanglingCleaned) isEvent() {}
+
+// Observer receives events synchronously while PIP holds its lock, so it
+// must not call back into the same PIP.
+type Observer func(Event)
+
+func (pip *PIP) AddObserver(observer Observer) {
+	pip.observersMu.Lock()
+	defer pip.observersMu.Unlock()
+	pip.observers = append(pip.observers, observer)
+}
+
+func (pip *PIP) emit(event Event) {
+	pip.observersMu.Lock()
+	observers := pip.observers
+	pip.observersMu.Unlock()
+	for _, observer := range observers {
+		observer(event)
+	}
+}
+
+func SlogObserver(logger *slog.Logger) Observer {
+	return func(event Event) {
+		ctx := context.Background()
+		switch e := event.(type) {
+		case ResolveStarted:
+			logger.LogAttrs(ctx, slog.LevelInfo, "resolve started", slog.Any("packages", e.Packages))
+		case PackageFetched:
+			logger.LogAttrs(ctx, slog.LevelInfo, "package fetched",
+				slog.String("package", e.Package),
+				slog.Int64("bytes", e.Bytes),
+				slog.Duration("duration", e.Duration),
+			)
+		case PackageMounted:
+			logger.LogAttrs(ctx, slog.LevelInfo, "package mounted", slog.String("package", e.Package))
+		case PackageSkipped:
+			logger.LogAttrs(ctx, slog.LevelDebug, "package already installed", slog.String("package", e.Package))
+		case PackageRemoved:
+			logger.LogAttrs(ctx, slog.LevelInfo, "package removed", slog.String("package", e.Package))
+		case DanglingCleaned:
+			logger.LogAttrs(ctx, slog.LevelInfo, "dangling package cleaned", slog.String("package", e.Package))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pip/fs"
+	"sort"
+)
+
+// MainGroup holds the requirements.txt of a project and every package
+// installed without a group.
+const MainGroup = "main"
+
+var ErrUnknownGroup = errors.New("unknown dependency group")
+
+// GroupOptions selects the dependency groups of a project to install. An
+// empty Only selects every group.
+type GroupOptions struct {
+	Only    []string
+	Without []string
+}
+
+// projectGroups reads the main group from requirements.txt and the others
+// from the [groups] table of the project manifest.
+func projectGroups(dir *fs.Dir) (map[string][]Requirement, error) {
+	pkgName := "project"
+	result := make(map[string][]Requirement)
+	meta, err := ReadMetadata(dir)
+	if err != nil && !errors.Is(err, ErrNoManifest) {
+		return nil, err
+	}
+	if meta != nil {
+		pkgName = meta.Name
+		for group, lines := range meta.Groups {
+			for _, line := range lines {
+				req, err := ParseRequirement(line)
+				if err != nil {
+					return nil, &ErrInvalidRequirements{Pkg: pkgName, Err: fmt.Errorf("group %s: %w", group, err)}
+				}
+				result[group] = append(result[group], req)
+			}
+		}
+	}
+	files, err := dir.ListFilesIn("")
+	if err != nil {
+		return nil, err
+	}
+	if Contains(files, "requirements.txt") {
+		content, err := dir.CatFile("requirements.txt")
+		if err != nil {
+			return nil, err
+		}
+		reqs, err := parseRequirementsTXT(pkgName, content)
+		if err != nil {
+			return nil, err
+		}
+		result[MainGroup] = append(reqs, result[MainGroup]...)
+	}
+	if len(result) == 0 {
+		return nil, &ErrInvalidRequirements{Pkg: pkgName, Err: errors.New("no requirements.txt and no groups")}
+	}
+	return result, nil
+}
+
+func (opts GroupOptions) selectGroups(available map[string][]Requirement) ([]string, error) {
+	for _, group := range append(append([]string{}, opts.Only...), opts.Without...) {
+		if _, ok := available[group]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+		}
+	}
+	selected := opts.Only
+	if len(selected) == 0 {
+		selected = make([]string, 0, len(available))
+		for group := range available {
+			selected = append(selected, group)
+		}
+	}
+	result := make([]string, 0, len(selected))
+	for _, group := range selected {
+		if !Contains(opts.Without, group) && !Contains(result, group) {
+			result = append(result, group)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (pip *PIP) InstallGroups(project *fs.Dir, opts GroupOptions) error {
+	return pip.InstallGroupsContext(context.Background(), project, opts)
+}
+
+// InstallGroupsContext installs the selected dependency groups of a project
+// and remembers which group asked for each package.
+func (pip *PIP) InstallGroupsContext(ctx context.Context, project *fs.Dir, opts GroupOptions) error {
+	available, err := projectGroups(project)
+	if err != nil {
+		return err
+	}
+	groups, err := opts.selectGroups(available)
+	if err != nil {
+		return err
+	}
+	specs := make([]Requirement, 0)
+	for _, group := range groups {
+		specs = append(specs, available[group]...)
+	}
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return pip.record(OpInstall, requirementNames(specs), func() error {
+		if err := pip.install(ctx, specs); err != nil {
+			return err
+		}
+		for _, group := range groups {
+			pip.addToGroup(group, requirementNames(available[group]))
+		}
+		return nil
+	})
+}
+
+func (pip *PIP) addToGroup(group string, pkgNames []string) {
+	for _, pkgName := range pkgNames {
+		if !Contains(pip.groups[group], pkgName) {
+			pip.groups[group] = append(pip.groups[group], pkgName)
+		}
+	}
+}
+
+func (pip *PIP) removeFromGroups(pkgName string) {
+	for group, members := range pip.groups {
+		members = RemoveFromList(members, pkgName)
+		if len(members) == 0 {
+			delete(pip.groups, group)
+		} else {
+			pip.groups[group] = members
+		}
+	}
+}
+
+// groupMembers returns the user packages installed for any of the groups.
+func (pip *PIP) groupMembers(groups []string) ([]string, error) {
+	result := make([]string, 0)
+	for _, group := range groups {
+		members, ok := pip.groups[group]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+		}
+		for _, pkgName := range members {
+			if !Contains(result, pkgName) {
+				result = append(result, pkgName)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Groups maps every installed group to its user packages.
+func (pip *PIP) Groups() map[string][]string {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	result := make(map[string][]string, len(pip.groups))
+	for group, members := range pip.groups {
+		result[group] = append([]string{}, members...)
+	}
+	return result
+}
+
+func (pip *PIP) UninstallGroup(group string) error {
+	return pip.UninstallGroupContext(context.Background(), group)
+}
+
+// UninstallGroupContext drops a group. Its packages stay installed while
+// another group still lists them or another package depends on them.
+func (pip *PIP) UninstallGroupContext(ctx context.Context, group string) error {
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	members, ok := pip.groups[group]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+	}
+	return pip.record(OpUninstall, members, func() error {
+		delete(pip.groups, group)
+		for _, pkgName := range members {
+			if !pip.inAnyGroup(pkgName) {
+				pip.userInstalled = RemoveFromList(pip.userInstalled, pkgName)
+			}
+		}
+		return pip.removeDanglings(ctx)
+	})
+}
+
+func (pip *PIP) inAnyGroup(pkgName string) bool {
+	for _, members := range pip.groups {
+		if Contains(members, pkgName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Freeze pins the installed packages needed by the given groups, or by every
+// user package when no group is given, as requirements.txt lines.
+func (pip *PIP) Freeze(groups ...string) ([]string, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	roots := pip.userInstalled
+	if len(groups) > 0 {
+		members, err := pip.groupMembers(groups)
+		if err != nil {
+			return nil, err
+		}
+		roots = members
+	}
+	needed, err := pip.neededDeps(context.Background(), roots)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(needed)
+	result := make([]string, 0, len(needed))
+	for _, pkgName := range needed {
+		dir, root := pip.pkgDir(pkgName)
+		meta, err := packageMetadata(pkgName, dir, root)
+		if err != nil {
+			return nil, err
+		}
+		line := pkgName
+		if meta.Version != "" {
+			line = fmt.Sprintf("%s==%s", pkgName, meta.Version)
+		}
+		result = append(result, line)
+	}
+	return result, nil
+}
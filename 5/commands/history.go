@@ -0,0 +1,241 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"pip/fs"
+	"time"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// DefaultHistoryLimit is how many operations are kept before the oldest are
+// dropped.
+const DefaultHistoryLimit = 1000
+
+var ErrNothingToUndo = errors.New("not enough operations to undo")
+
+type OperationKind string
+
+const (
+	OpInstall   OperationKind = "install"
+	OpUninstall OperationKind = "uninstall"
+	OpFix       OperationKind = "fix"
+	OpUndo      OperationKind = "undo"
+)
+
+// Operation is one entry of the install history. Installed and Removed map
+// the packages the operation mounted or removed to their versions.
+type Operation struct {
+	Time      time.Time
+	User      string
+	Kind      OperationKind
+	Packages  []string
+	Installed map[string]string
+	Removed   map[string]string
+	Err       error
+	Undone    bool
+
+	// state before the operation, restored by Undo
+	userInstalled []string
+	groups        map[string][]string
+	editable      map[string]*fs.Dir
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func cloneGroups(groups map[string][]string) map[string][]string {
+	result := make(map[string][]string, len(groups))
+	for group, members := range groups {
+		result[group] = append([]string{}, members...)
+	}
+	return result
+}
+
+func cloneVersions(versions map[string]string) map[string]string {
+	result := make(map[string]string, len(versions))
+	for k, v := range versions {
+		result[k] = v
+	}
+	return result
+}
+
+// record runs fn as one operation of the history. It must be called with the
+// lock held.
+func (pip *PIP) record(kind OperationKind, pkgNames []string, fn func() error) error {
+	editable := make(map[string]*fs.Dir, len(pip.editable))
+	for pkgName, src := range pip.editable {
+		editable[pkgName] = src
+	}
+	op := &Operation{
+		Time:          time.Now(),
+		User:          currentUser(),
+		Kind:          kind,
+		Packages:      append([]string{}, pkgNames...),
+		Installed:     make(map[string]string),
+		Removed:       make(map[string]string),
+		userInstalled: append([]string{}, pip.userInstalled...),
+		groups:        cloneGroups(pip.groups),
+		editable:      editable,
+	}
+	pip.pending = op
+	op.Err = fn()
+	pip.pending = nil
+	pip.history = append(pip.history, op)
+	if pip.historyLimit > 0 && len(pip.history) > pip.historyLimit {
+		pip.history = append([]*Operation{}, pip.history[len(pip.history)-pip.historyLimit:]...)
+	}
+	return op.Err
+}
+
+func (pip *PIP) noteInstalled(pkgName, version string) {
+	if pip.pending == nil {
+		return
+	}
+	if _, ok := pip.pending.Removed[pkgName]; ok {
+		delete(pip.pending.Removed, pkgName)
+		return
+	}
+	pip.pending.Installed[pkgName] = version
+}
+
+func (pip *PIP) noteRemoved(pkgName, version string) {
+	if pip.pending == nil {
+		return
+	}
+	if _, ok := pip.pending.Installed[pkgName]; ok {
+		delete(pip.pending.Installed, pkgName)
+		return
+	}
+	pip.pending.Removed[pkgName] = version
+}
+
+// History returns the recorded operations, oldest first.
+func (pip *PIP) History() []Operation {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	result := make([]Operation, 0, len(pip.history))
+	for _, op := range pip.history {
+		cp := *op
+		cp.Packages = append([]string{}, op.Packages...)
+		cp.Installed = cloneVersions(op.Installed)
+		cp.Removed = cloneVersions(op.Removed)
+		result = append(result, cp)
+	}
+	return result
+}
+
+// SetHistoryLimit sets how many operations are kept. Zero or less keeps all.
+func (pip *PIP) SetHistoryLimit(limit int) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.historyLimit = limit
+	if limit > 0 && len(pip.history) > limit {
+		pip.history = append([]*Operation{}, pip.history[len(pip.history)-limit:]...)
+	}
+}
+
+func (pip *PIP) Undo(n int) error {
+	return pip.UndoContext(context.Background(), n)
+}
+
+// UndoContext reverts the last n successful operations that are not undone
+// yet. Removed packages are fetched again from GOPI, so they get the version
+// GOPI serves now. The undo itself is recorded in the history.
+func (pip *PIP) UndoContext(ctx context.Context, n int) error {
+	unlock, err := pip.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	targets := make([]*Operation, 0, n)
+	for i := len(pip.history) - 1; i >= 0 && len(targets) < n; i-- {
+		op := pip.history[i]
+		if op.Kind == OpUndo || op.Undone || op.Err != nil {
+			continue
+		}
+		targets = append(targets, op)
+	}
+	if len(targets) < n {
+		return fmt.Errorf("%w: only %d in history", ErrNothingToUndo, len(targets))
+	}
+	return pip.record(OpUndo, nil, func() error {
+		for _, op := range targets {
+			if err := pip.revert(ctx, op); err != nil {
+				return err
+			}
+			op.Undone = true
+		}
+		return nil
+	})
+}
+
+func (pip *PIP) revert(ctx context.Context, op *Operation) error {
+	installed := make([]string, 0, len(op.Installed))
+	for _, pkgName := range sortedKeys(op.Installed) {
+		if Contains(pip.allInstalled, pkgName) {
+			installed = append(installed, pkgName)
+		}
+	}
+	if err := pip.uninstallForce(ctx, installed...); err != nil {
+		return err
+	}
+	for _, pkgName := range sortedKeys(op.Removed) {
+		if Contains(pip.allInstalled, pkgName) {
+			continue
+		}
+		src, ok := op.editable[pkgName]
+		if !ok {
+			if err := pip.copyFromGopi(ctx, pkgName); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := pip.installDir.Link(pkgName, src); err != nil {
+			return err
+		}
+		pip.editable[pkgName] = src
+		pip.allInstalled = append(pip.allInstalled, pkgName)
+		pip.noteInstalled(pkgName, op.Removed[pkgName])
+	}
+	userInstalled := make([]string, 0, len(op.userInstalled))
+	for _, pkgName := range op.userInstalled {
+		if Contains(pip.allInstalled, pkgName) {
+			userInstalled = append(userInstalled, pkgName)
+		}
+	}
+	pip.userInstalled = userInstalled
+	pip.groups = cloneGroups(op.groups)
+	return nil
+}
+
+// historyPackages returns every package the history has seen installed or
+// removed together with the installed ones. The names an operation was asked
+// for are left out, as it may have failed without installing them.
+func (pip *PIP) historyPackages() []string {
+	seen := make(map[string]bool)
+	AddAllToMap(seen, pip.allInstalled)
+	for _, op := range pip.history {
+		AddAllToMap(seen, sortedKeys(op.Installed))
+		AddAllToMap(seen, sortedKeys(op.Removed))
+	}
+	return MapToSlice(seen)
+}
+
+func (pip *PIP) OnceInstalledPackages() []string {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	return pip.historyPackages()
+}
+
+func (pip *PIP) LocalSearch(term string) []string {
+	return fuzzy.Find(term, pip.OnceInstalledPackages())
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pip/fs"
+	"strings"
+	"time"
+)
+
+const DefaultHookTimeout = 30 * time.Second
+
+var ErrHookFailed = errors.New("hook failed")
+
+// Hooks are shell scripts a package runs around its install and uninstall.
+// A hook runs inside the package's directory in the install dir and is
+// confined to it: it can read and execute files anywhere, but only change
+// files of its own package. Where hooks cannot be confined they are refused,
+// failing the install or uninstall of a package that has any.
+type Hooks struct {
+	PreInstall   string `toml:"pre-install"`
+	PostInstall  string `toml:"post-install"`
+	PreUninstall string `toml:"pre-uninstall"`
+}
+
+// SetHooksEnabled turns running the hooks of packages on or off. Hooks are on
+// by default.
+func (pip *PIP) SetHooksEnabled(enabled bool) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.hooksEnabled = enabled
+}
+
+func (pip *PIP) SetHookTimeout(timeout time.Duration) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.hookTimeout = timeout
+}
+
+// runHook runs a hook script with sh confined to root of dir, which is the
+// package's own directory, and kills it after the configured timeout.
+func (pip *PIP) runHook(ctx context.Context, pkgName, stage, script string, dir *fs.Dir, root string) error {
+	if !pip.hooksEnabled || strings.TrimSpace(script) == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, pip.hookTimeout)
+	defer cancel()
+	out, err := dir.Run(ctx, root, "sh", "-c", script)
+	if err != nil {
+		return fmt.Errorf("%w: %s %s: %v: %s", ErrHookFailed, pkgName, stage, err, strings.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"pip/fs"
+	"sort"
+	"strings"
+)
+
+type IntegrityReport struct {
+	Package  string
+	Modified []string
+	Missing  []string
+	Extra    []string
+}
+
+// fileHashes maps every file under root, relative to root, to the hex
+// sha256 of its content.
+func fileHashes(dir *fs.Dir, root string) (map[string]string, error) {
+	files, err := dir.ListFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := dir.CatFile(file)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256([]byte(content))
+		rel := strings.TrimPrefix(strings.TrimPrefix(file, root), "/")
+		result[rel] = hex.EncodeToString(sum[:])
+	}
+	return result, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (pip *PIP) recordHashes(pkgName string) error {
+	hashes, err := fileHashes(pip.installDir, pkgName)
+	if err != nil {
+		return err
+	}
+	pip.hashes[pkgName] = hashes
+	return nil
+}
+
+func (pip *PIP) verifyPackage(pkgName string) IntegrityReport {
+	report := IntegrityReport{Package: pkgName}
+	recorded := pip.hashes[pkgName]
+	current, err := fileHashes(pip.installDir, pkgName)
+	if err != nil {
+		// the package directory itself is gone
+		current = map[string]string{}
+	}
+	for _, file := range sortedKeys(recorded) {
+		hash, ok := current[file]
+		switch {
+		case !ok:
+			report.Missing = append(report.Missing, file)
+		case hash != recorded[file]:
+			report.Modified = append(report.Modified, file)
+		}
+	}
+	for _, file := range sortedKeys(current) {
+		if _, ok := recorded[file]; !ok {
+			report.Extra = append(report.Extra, file)
+		}
+	}
+	return report
+}
+
+func (r IntegrityReport) Clean() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+func (pip *PIP) verify() []IntegrityReport {
+	result := make([]IntegrityReport, 0)
+	for _, pkgName := range pip.allInstalled {
+		if _, ok := pip.hashes[pkgName]; !ok {
+			continue
+		}
+		if report := pip.verifyPackage(pkgName); !report.Clean() {
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+// Verify compares the files of every installed package with the hashes
+// recorded when it was mounted and reports the packages that differ.
+func (pip *PIP) Verify() []IntegrityReport {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	return pip.verify()
+}
+
+func (pip *PIP) reinstallCorrupted(ctx context.Context) error {
+	for _, report := range pip.verify() {
+		if err := pip.reinstall(ctx, report.Package); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reinstall replaces an installed package with a fresh copy from GOPI. The old
+// copy is kept aside until the new one is in place and put back if fetching,
+// verifying or mounting the new one fails.
+func (pip *PIP) reinstall(ctx context.Context, pkgName string) error {
+	version := ""
+	if meta, err := pip.installedMetadata(pkgName); err == nil {
+		version = meta.Version
+	}
+	aside := "." + pkgName + ".corrupted"
+	_, err := pip.installDir.Stat(pkgName)
+	kept := err == nil
+	if kept {
+		if err := pip.installDir.Move(pkgName, aside); err != nil {
+			return err
+		}
+	}
+	allInstalled := append([]string{}, pip.allInstalled...)
+	hashes := pip.hashes[pkgName]
+	pip.allInstalled = RemoveFromList(pip.allInstalled, pkgName)
+	delete(pip.hashes, pkgName)
+	// cancels out with the reinstall, undoing a repair keeps the package
+	pip.noteRemoved(pkgName, version)
+	err = pip.copyFromGopi(ctx, pkgName)
+	if err == nil {
+		if kept {
+			return pip.installDir.Remove(aside)
+		}
+		return nil
+	}
+	pip.allInstalled = allInstalled
+	pip.hashes[pkgName] = hashes
+	pip.noteInstalled(pkgName, version)
+	if rmErr := pip.installDir.Remove(pkgName); rmErr != nil {
+		return errors.Join(err, rmErr)
+	}
+	if kept {
+		if mvErr := pip.installDir.Move(aside, pkgName); mvErr != nil {
+			return errors.Join(err, mvErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// NoAssertion is reported for packages whose license cannot be detected.
+const NoAssertion = "NOASSERTION"
+
+var ErrLicenseDenied = errors.New("license denied by policy")
+
+type LicenseInfo struct {
+	Package string
+	License string
+	Source  string
+}
+
+type LicensePolicy struct {
+	Deny []string
+}
+
+type licenseRule struct {
+	id      string
+	phrases []string
+}
+
+// titledLicenses are recognised by their title, which has to be in the
+// header of the text: their bodies quote each other, the GPL names the
+// AGPL and the MPL names both. Versions are matched as whole words.
+var titledLicenses = []licenseRule{
+	{"AGPL-3.0", []string{"gnu affero general public license version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license version 3"}},
+	{"GPL-2.0", []string{"gnu general public license version 2"}},
+	{"Apache-2.0", []string{"apache license version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license version 2.0"}},
+}
+
+// licenseRules have no title and are recognised by phrases of their body.
+// Rules are ordered so that more specific texts are matched first.
+var licenseRules = []licenseRule{
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", []string{"permission to use copy modify and/or distribute this software for any purpose"}},
+	{"MIT", []string{"permission is hereby granted free of charge", "the above copyright notice and this permission notice shall be included"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// licenseHeaderLines is how many non-empty lines the title may come after,
+// leaving room for copyright lines.
+const licenseHeaderLines = 10
+
+// normalizeLicense lowercases text and turns punctuation other than the dots
+// of version numbers into single spaces.
+func normalizeLicense(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '.', r == '/':
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	return " " + strings.Join(strings.Fields(text), " ") + " "
+}
+
+func matchLicense(text string, rules []licenseRule) string {
+	for _, rule := range rules {
+		matched := true
+		for _, phrase := range rule.phrases {
+			if !strings.Contains(text, " "+phrase+" ") && !strings.Contains(text, " "+phrase+". ") {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule.id
+		}
+	}
+	return NoAssertion
+}
+
+func DetectLicense(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if _, id, found := strings.Cut(line, "SPDX-License-Identifier:"); found {
+			return strings.TrimSpace(id)
+		}
+	}
+	var header []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			header = append(header, line)
+		}
+		if len(header) == licenseHeaderLines {
+			break
+		}
+	}
+	if id := matchLicense(normalizeLicense(strings.Join(header, "\n")), titledLicenses); id != NoAssertion {
+		return id
+	}
+	return matchLicense(normalizeLicense(text), licenseRules)
+}
+
+func isLicenseFile(file string) bool {
+	name := strings.ToUpper(path.Base(file))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	return name == "LICENSE" || name == "LICENCE" || name == "COPYING"
+}
+
+func (pip *PIP) packageLicense(pkgName string) (LicenseInfo, error) {
+	info := LicenseInfo{Package: pkgName, License: NoAssertion}
+	dir, root := pip.pkgDir(pkgName)
+	meta, err := packageMetadata(pkgName, dir, root)
+	if err != nil {
+		return info, err
+	}
+	if meta.License != "" {
+		info.License = meta.License
+		info.Source = ManifestFile
+		return info, nil
+	}
+	files, err := dir.ListFilesIn(root)
+	if err != nil {
+		return info, err
+	}
+	for _, file := range files {
+		if path.Dir(file) != path.Clean(root) || !isLicenseFile(file) {
+			continue
+		}
+		content, err := dir.CatFile(file)
+		if err != nil {
+			return info, err
+		}
+		if id := DetectLicense(content); id != NoAssertion {
+			info.License = id
+			info.Source = path.Base(file)
+			return info, nil
+		}
+	}
+	return info, nil
+}
+
+func (pip *PIP) Licenses() ([]LicenseInfo, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	return pip.licenses()
+}
+
+func (pip *PIP) licenses() ([]LicenseInfo, error) {
+	result := make([]LicenseInfo, 0, len(pip.allInstalled))
+	for _, pkgName := range pip.allInstalled {
+		info, err := pip.packageLicense(pkgName)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func (pip *PIP) SetLicensePolicy(policy LicensePolicy) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.licensePolicy = policy
+}
+
+// Denied reports whether an SPDX expression is forbidden by the policy. An
+// "OR" expression is allowed as long as one alternative is allowed, an "AND"
+// expression only when all its terms are. Expressions that cannot be parsed
+// are denied if they name any denied license.
+func (policy LicensePolicy) Denied(expr string) bool {
+	e, err := parseLicenseExpr(expr)
+	if err != nil {
+		for _, token := range licenseTokens(expr) {
+			if policy.deniedID(token) {
+				return true
+			}
+		}
+		return false
+	}
+	return policy.denied(e)
+}
+
+func (policy LicensePolicy) denied(e *licenseExpr) bool {
+	switch e.op {
+	case "OR":
+		for _, term := range e.terms {
+			if !policy.denied(term) {
+				return false
+			}
+		}
+		return true
+	case "AND":
+		for _, term := range e.terms {
+			if policy.denied(term) {
+				return true
+			}
+		}
+		return false
+	}
+	return policy.deniedID(e.id)
+}
+
+func (policy LicensePolicy) deniedID(id string) bool {
+	for _, deny := range policy.Deny {
+		if licenseFamily(id) == licenseFamily(deny) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pip *PIP) CheckLicenses() error {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	licenses, err := pip.licenses()
+	if err != nil {
+		return err
+	}
+	violations := make([]string, 0)
+	for _, info := range licenses {
+		if pip.licensePolicy.Denied(info.License) {
+			violations = append(violations, fmt.Sprintf("%s (%s)", info.Package, info.License))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: %s", ErrLicenseDenied, strings.Join(violations, ", "))
+	}
+	return nil
+}
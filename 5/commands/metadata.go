@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"pip/fs"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const ManifestFile = "gopi.toml"
+
+var ErrNoManifest = errors.New("package has no " + ManifestFile)
+
+type PackageMetadata struct {
+	Name        string              `toml:"name"`
+	Version     string              `toml:"version"`
+	Description string              `toml:"description"`
+	License     string              `toml:"license"`
+	Authors     []string            `toml:"authors"`
+	Keywords    []string            `toml:"keywords"`
+	Hooks       Hooks               `toml:"hooks"`
+	Groups      map[string][]string `toml:"groups"`
+}
+
+func ParseMetadata(content string) (*PackageMetadata, error) {
+	meta := &PackageMetadata{}
+	md, err := toml.Decode(content, meta)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("invalid %s: unknown key %s", ManifestFile, undecoded[0])
+	}
+	return meta, nil
+}
+
+func (m *PackageMetadata) Validate() error {
+	if m.Name == "" {
+		return fmt.Errorf("invalid %s: name is required", ManifestFile)
+	}
+	if strings.ContainsAny(m.Name, " \t/\\<>=!") {
+		return fmt.Errorf("invalid %s: bad package name %q", ManifestFile, m.Name)
+	}
+	if m.Version != "" && !ValidVersion(m.Version) {
+		return fmt.Errorf("invalid %s: bad version %q", ManifestFile, m.Version)
+	}
+	return nil
+}
+
+func readMetadata(dir *fs.Dir, root string) (*PackageMetadata, error) {
+	file := path.Join(root, ManifestFile)
+	files, err := dir.ListFilesIn(root)
+	if err != nil {
+		return nil, err
+	}
+	if !Contains(files, file) {
+		return nil, ErrNoManifest
+	}
+	content, err := dir.CatFile(file)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := ParseMetadata(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.Validate(); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+func ReadMetadata(dir *fs.Dir) (*PackageMetadata, error) {
+	return readMetadata(dir, "")
+}
+
+// packageMetadata reads and checks the manifest of a downloaded package.
+// Packages without a manifest get metadata holding only their name.
+func packageMetadata(pkgName string, dir *fs.Dir, root string) (*PackageMetadata, error) {
+	meta, err := readMetadata(dir, root)
+	if errors.Is(err, ErrNoManifest) {
+		return &PackageMetadata{Name: pkgName}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pkg %s: %w", pkgName, err)
+	}
+	if meta.Name != pkgName {
+		return nil, fmt.Errorf("pkg %s: manifest declares name %s", pkgName, meta.Name)
+	}
+	return meta, nil
+}
+
+// metadata reads editable packages from their project and everything else
+// from GOPI.
+func (pip *PIP) metadata(ctx context.Context, pkgName string) (*PackageMetadata, error) {
+	if src, ok := pip.editable[pkgName]; ok {
+		return packageMetadata(pkgName, src, "")
+	}
+	return pip.remoteMetadata(ctx, pkgName)
+}
+
+func (pip *PIP) remoteMetadata(ctx context.Context, pkgName string) (*PackageMetadata, error) {
+	dl, err := pip.registry.GetContext(ctx, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	defer dl.Close()
+	return packageMetadata(pkgName, dl, "")
+}
+
+// Show returns the metadata of an installed package, or of the registry's
+// copy when it is not installed.
+func (pip *PIP) Show(pkgName string) (*PackageMetadata, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	if Contains(pip.allInstalled, pkgName) {
+		return pip.installedMetadata(pkgName)
+	}
+	return pip.remoteMetadata(context.Background(), pkgName)
+}
+
+// installedMetadata reads the manifest of an installed package.
+func (pip *PIP) installedMetadata(pkgName string) (*PackageMetadata, error) {
+	dir, root := pip.pkgDir(pkgName)
+	return packageMetadata(pkgName, dir, root)
+}
+
+// resolve checks every version constraint in the dependency closure of
+// specs against the installed versions, and against the versions the
+// registry serves for packages still to be installed.
+func (pip *PIP) resolve(ctx context.Context, specs []Requirement) error {
+	constraints := make(map[string][]Requirement)
+	order := make([]string, 0)
+	queue := append([]Requirement{}, specs...)
+	for len(queue) > 0 {
+		req := queue[0]
+		queue = queue[1:]
+		if _, seen := constraints[req.Name]; !seen {
+			order = append(order, req.Name)
+			reqs, err := pip.requirements(ctx, req.Name)
+			if err != nil {
+				return err
+			}
+			queue = append(queue, reqs...)
+		}
+		constraints[req.Name] = append(constraints[req.Name], req)
+	}
+	for _, name := range order {
+		// installed packages are kept as they are, so they must fit
+		installed := Contains(pip.allInstalled, name)
+		var meta *PackageMetadata
+		var err error
+		if installed {
+			meta, err = pip.installedMetadata(name)
+		} else {
+			meta, err = pip.metadata(ctx, name)
+		}
+		if err != nil {
+			return err
+		}
+		for _, req := range constraints[name] {
+			if req.Satisfied(meta.Version) {
+				continue
+			}
+			if installed {
+				return fmt.Errorf("%s is required but version %q is installed", req, meta.Version)
+			}
+			return fmt.Errorf("%s is required but version %q is available", req, meta.Version)
+		}
+		if err := pip.checkAdvisories(meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConstraints checks the version constraints every installed package
+// puts on its installed dependencies.
+func (pip *PIP) checkConstraints() error {
+	for _, pkgName := range pip.allInstalled {
+		dir, root := pip.pkgDir(pkgName)
+		content, err := dir.CatFile(path.Join(root, "requirements.txt"))
+		if err != nil {
+			return &ErrInvalidRequirements{Pkg: pkgName, Err: err}
+		}
+		reqs, err := parseRequirementsTXT(pkgName, content)
+		if err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			if !Contains(pip.allInstalled, req.Name) {
+				continue
+			}
+			meta, err := pip.installedMetadata(req.Name)
+			if err != nil {
+				return err
+			}
+			if !req.Satisfied(meta.Version) {
+				return fmt.Errorf("%s requires %s but version %q is installed", pkgName, req, meta.Version)
+			}
+		}
+	}
+	return nil
+}
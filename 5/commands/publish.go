@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"path"
+	"pip/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrNotWritable    = errors.New("registry does not accept uploads")
+	ErrVersionExists  = errors.New("version already published")
+	ErrInvalidPackage = errors.New("invalid package")
+)
+
+type Archive struct {
+	Metadata PackageMetadata
+	Data     []byte
+}
+
+// WritableGOPI is implemented by registries that packages can be published to.
+type WritableGOPI interface {
+	GOPI
+	Put(*Archive) error
+}
+
+// Pack writes every file of dir into a gzipped tarball. Entries are sorted
+// and carry no timestamps so packing the same tree twice gives equal bytes.
+// Files are streamed, and only the executable bit of their mode is kept.
+func Pack(dir *fs.Dir) ([]byte, error) {
+	files, err := dir.ListFilesIn("")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		info, err := dir.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		mode := int64(0644)
+		if info.Mode()&0100 != 0 {
+			mode = 0755
+		}
+		hdr := &tar.Header{
+			Name:     file,
+			Mode:     mode,
+			Size:     info.Size(),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if err := copyFrom(tw, dir, file); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func copyFrom(w io.Writer, dir *fs.Dir, file string) error {
+	r, err := dir.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func makeParents(dir *fs.Dir, file string) error {
+	parent := path.Dir(file)
+	if parent == "." {
+		return nil
+	}
+	if err := makeParents(dir, parent); err != nil {
+		return err
+	}
+	if err := dir.CreateDir(parent); err != nil && !errors.Is(err, iofs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// Unpack extracts an archive into a new temporary directory.
+func Unpack(data []byte) (*fs.Dir, error) {
+	dir := fs.MkDir()
+	if err := UnpackTo(dir, data); err != nil {
+		dir.Remove("")
+		return nil, err
+	}
+	return dir, nil
+}
+
+func UnpackTo(dir *fs.Dir, data []byte) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%w: bad entry %q", ErrInvalidPackage, hdr.Name)
+		}
+		if err := makeParents(dir, name); err != nil {
+			return err
+		}
+		w, err := dir.OpenWriter(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			w.Close()
+			return fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if hdr.Mode&0100 != 0 {
+			if err := dir.Chmod(name, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateProject checks the manifest and requirements.txt of a package tree.
+func validateProject(dir *fs.Dir) (*PackageMetadata, error) {
+	meta, err := ReadMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	if meta.Version == "" {
+		return nil, fmt.Errorf("%w: %s has no version", ErrInvalidPackage, ManifestFile)
+	}
+	reqs, err := dir.CatFile("requirements.txt")
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing requirements.txt", ErrInvalidPackage)
+	}
+	if _, err := parseRequirementsTXT(meta.Name, reqs); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	return meta, nil
+}
+
+func (pip *PIP) Build(projectDir *fs.Dir) (*Archive, error) {
+	meta, err := validateProject(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := Pack(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{Metadata: *meta, Data: data}, nil
+}
+
+func (pip *PIP) Publish(archive *Archive) error {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	registry, ok := pip.gopi.(WritableGOPI)
+	if !ok {
+		return ErrNotWritable
+	}
+	dir := fs.MemDir()
+	if err := UnpackTo(dir, archive.Data); err != nil {
+		return err
+	}
+	meta, err := validateProject(dir)
+	if err != nil {
+		return err
+	}
+	if meta.Name != archive.Metadata.Name || meta.Version != archive.Metadata.Version {
+		return fmt.Errorf("%w: archive holds %s %s", ErrInvalidPackage, meta.Name, meta.Version)
+	}
+	existing, err := pip.remoteMetadata(context.Background(), meta.Name)
+	if err != nil && !errors.Is(err, ErrPackageNotFound) {
+		return err
+	}
+	// the registry serves one version per package, so an older one would
+	// replace the newest
+	if err == nil && CompareVersions(existing.Version, meta.Version) >= 0 {
+		return fmt.Errorf("%w: %s %s, %s is published", ErrVersionExists, meta.Name, meta.Version, existing.Version)
+	}
+	if pip.signingKey != nil {
+		if err := Sign(dir, pip.signingKey); err != nil {
+			return err
+		}
+		data, err := Pack(dir)
+		if err != nil {
+			return err
+		}
+		archive = &Archive{Metadata: archive.Metadata, Data: data}
+	}
+	return registry.Put(archive)
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// Searchable is implemented by registries that can list what they serve.
+type Searchable interface {
+	GOPI
+	Index() ([]PackageMetadata, error)
+}
+
+type SearchResult struct {
+	Name        string
+	Version     string
+	Description string
+	Score       int
+}
+
+var ErrNotSearchable = errors.New("registry does not support search")
+
+const (
+	scoreExactName   = 1000
+	scorePrefixName  = 500
+	scoreFuzzyName   = 250
+	scoreExactKey    = 200
+	scoreFuzzyKey    = 100
+	scoreDescription = 50
+)
+
+type indexedEntry struct {
+	entry    PackageMetadata
+	name     string
+	keywords []string
+	words    []string
+}
+
+type searchIndex struct {
+	entries []indexedEntry
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	})
+}
+
+func newSearchIndex(entries []PackageMetadata) *searchIndex {
+	idx := &searchIndex{entries: make([]indexedEntry, 0, len(entries))}
+	for _, e := range entries {
+		keywords := make([]string, 0, len(e.Keywords))
+		for _, k := range e.Keywords {
+			keywords = append(keywords, strings.ToLower(k))
+		}
+		idx.entries = append(idx.entries, indexedEntry{
+			entry:    e,
+			name:     strings.ToLower(e.Name),
+			keywords: keywords,
+			words:    tokenize(e.Description),
+		})
+	}
+	return idx
+}
+
+func matchesAllWords(terms []string, words []string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func clamp(score, min int) int {
+	if score < min {
+		return min
+	}
+	return score
+}
+
+// fuzzyNameScore weighs a fuzzy name match by how much of the name the term
+// covers, so only close matches outrank an exact keyword.
+func fuzzyNameScore(term, name string, dist int) int {
+	return clamp(scoreFuzzyName*len(term)/(len(term)+dist), 1)
+}
+
+func (e *indexedEntry) score(term string, terms []string) int {
+	best := 0
+	keep := func(s int) {
+		if s > best {
+			best = s
+		}
+	}
+	switch {
+	case e.name == term:
+		keep(scoreExactName)
+	case strings.HasPrefix(e.name, term):
+		keep(clamp(scorePrefixName-(len(e.name)-len(term)), scoreFuzzyName+1))
+	default:
+		if dist := fuzzy.RankMatchNormalizedFold(term, e.name); dist >= 0 {
+			keep(fuzzyNameScore(term, e.name, dist))
+		}
+	}
+	for _, k := range e.keywords {
+		if k == term {
+			keep(scoreExactKey)
+		} else if strings.HasPrefix(k, term) || fuzzy.MatchNormalizedFold(term, k) {
+			keep(scoreFuzzyKey)
+		}
+	}
+	if matchesAllWords(terms, e.words) {
+		keep(scoreDescription)
+	}
+	return best
+}
+
+func (idx *searchIndex) search(term string) []SearchResult {
+	term = strings.ToLower(strings.TrimSpace(term))
+	terms := tokenize(term)
+	result := make([]SearchResult, 0)
+	for i := range idx.entries {
+		e := &idx.entries[i]
+		if s := e.score(term, terms); s > 0 {
+			result = append(result, SearchResult{
+				Name:        e.entry.Name,
+				Version:     e.entry.Version,
+				Description: e.entry.Description,
+				Score:       s,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// searchCache keeps the index built for the last entries the registry
+// listed, so searching or paging again does not rebuild it.
+type searchCache struct {
+	mu      sync.Mutex
+	entries []PackageMetadata
+	index   *searchIndex
+}
+
+func (c *searchCache) get(entries []PackageMetadata) *searchIndex {
+	sorted := append([]PackageMetadata{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index == nil || !reflect.DeepEqual(c.entries, sorted) {
+		c.entries, c.index = sorted, newSearchIndex(sorted)
+	}
+	return c.index
+}
+
+func (pip *PIP) Search(term string) ([]SearchResult, error) {
+	s, ok := pip.gopi.(Searchable)
+	if !ok {
+		return nil, ErrNotSearchable
+	}
+	entries, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	return pip.searchCache.get(entries).search(term), nil
+}
+
+// SearchPage returns the 1-based page of ranked results and the total
+// number of matches.
+func (pip *PIP) SearchPage(term string, page, pageSize int) ([]SearchResult, int, error) {
+	if page < 1 || pageSize < 1 {
+		return nil, 0, errors.New("page and page size should be positive")
+	}
+	all, err := pip.Search(term)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := (page - 1) * pageSize
+	if start >= len(all) {
+		return []SearchResult{}, len(all), nil
+	}
+	end := start + pageSize
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], len(all), nil
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"pip/fs"
+	"strings"
+)
+
+const SignatureFile = "gopi.sig"
+
+var (
+	ErrUnsigned     = errors.New("package is not signed")
+	ErrBadSignature = errors.New("package signature does not match any trusted key")
+)
+
+// treeDigest hashes the paths and contents of every file under root except
+// the signature itself.
+func treeDigest(dir *fs.Dir, root string) ([]byte, error) {
+	hashes, err := fileHashes(dir, root)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	for _, rel := range sortedKeys(hashes) {
+		if rel == SignatureFile {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", rel, hashes[rel])
+	}
+	return h.Sum(nil), nil
+}
+
+func Sign(dir *fs.Dir, key ed25519.PrivateKey) error {
+	digest, err := treeDigest(dir, "")
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest))
+	files, err := dir.ListFilesIn("")
+	if err != nil {
+		return err
+	}
+	if !Contains(files, SignatureFile) {
+		if err := dir.CreateFile(SignatureFile); err != nil {
+			return err
+		}
+	}
+	return dir.WriteToFile(SignatureFile, sig)
+}
+
+func verifySignature(dir *fs.Dir, root string, keys []ed25519.PublicKey) error {
+	files, err := dir.ListFilesIn(root)
+	if err != nil {
+		return err
+	}
+	sigFile := path.Join(root, SignatureFile)
+	if !Contains(files, sigFile) {
+		return ErrUnsigned
+	}
+	content, err := dir.CatFile(sigFile)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return ErrBadSignature
+	}
+	digest, err := treeDigest(dir, root)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, digest, sig) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+func (pip *PIP) SetSigningKey(key ed25519.PrivateKey) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.signingKey = key
+}
+
+// TrustKey adds a key to the keyring. Once the keyring is not empty every
+// installed package must carry a signature made by one of its keys.
+func (pip *PIP) TrustKey(key ed25519.PublicKey) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	pip.trustedKeys = append(pip.trustedKeys, key)
+}
+
+// AllowUnsigned lets the given packages be installed without a signature
+// while the keyring is in use. A package that does carry a signature must
+// still match a trusted key.
+func (pip *PIP) AllowUnsigned(pkgNames ...string) {
+	pip.mu.Lock()
+	defer pip.mu.Unlock()
+	for _, pkgName := range pkgNames {
+		pip.allowUnsigned[pkgName] = true
+	}
+}
+
+func (pip *PIP) checkSignature(pkgName string, dir *fs.Dir) error {
+	if len(pip.trustedKeys) == 0 {
+		return nil
+	}
+	err := verifySignature(dir, "", pip.trustedKeys)
+	if errors.Is(err, ErrUnsigned) && pip.allowUnsigned[pkgName] {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("pkg %s: %w", pkgName, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"pip/fs"
+	"sort"
+)
+
+// PackageSize is the disk usage of an installed package. Editable packages
+// are links to their project and take no space in the install dir.
+type PackageSize struct {
+	Name     string
+	Editable bool
+	fs.Usage
+}
+
+// DiskUsage reports the packages from largest to smallest. Total measures
+// the whole install dir, counting files hardlinked between packages once.
+type DiskUsage struct {
+	Packages []PackageSize
+	Total    fs.Usage
+}
+
+func (pip *PIP) Sizes() (*DiskUsage, error) {
+	pip.mu.RLock()
+	defer pip.mu.RUnlock()
+	usage := &DiskUsage{Packages: make([]PackageSize, 0, len(pip.allInstalled))}
+	for _, pkgName := range pip.allInstalled {
+		_, editable := pip.editable[pkgName]
+		size := PackageSize{Name: pkgName, Editable: editable}
+		if !editable {
+			var err error
+			if size.Usage, err = pip.installDir.Usage(pkgName); err != nil {
+				return nil, err
+			}
+		}
+		usage.Packages = append(usage.Packages, size)
+	}
+	sort.SliceStable(usage.Packages, func(i, j int) bool {
+		a, b := usage.Packages[i], usage.Packages[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+	total, err := pip.installDir.Usage("")
+	if err != nil {
+		return nil, err
+	}
+	usage.Total = total
+	return usage, nil
+}
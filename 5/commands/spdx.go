@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// licenseExpr is a parsed SPDX license expression: either a single license
+// id or terms joined by AND or OR. License exceptions are dropped, as
+// "GPL-2.0 WITH Classpath-exception-2.0" is still GPL-2.0 to a policy.
+type licenseExpr struct {
+	op    string
+	id    string
+	terms []*licenseExpr
+}
+
+// licenseTokens splits an expression into ids, operators and parentheses.
+func licenseTokens(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseLicenseExpr parses an SPDX expression. WITH binds tighter than AND,
+// which binds tighter than OR.
+func parseLicenseExpr(expr string) (*licenseExpr, error) {
+	p := &licenseParser{tokens: licenseTokens(expr)}
+	e, err := p.parse("OR")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("license %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func (p *licenseParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], op)
+}
+
+// parse reads terms joined by op, with AND terms inside OR ones.
+func (p *licenseParser) parse(op string) (*licenseExpr, error) {
+	next := p.with
+	if op == "OR" {
+		next = func() (*licenseExpr, error) { return p.parse("AND") }
+	}
+	term, err := next()
+	if err != nil {
+		return nil, err
+	}
+	e := &licenseExpr{op: op, terms: []*licenseExpr{term}}
+	for p.peek(op) {
+		p.pos++
+		term, err := next()
+		if err != nil {
+			return nil, err
+		}
+		e.terms = append(e.terms, term)
+	}
+	if len(e.terms) == 1 {
+		return term, nil
+	}
+	return e, nil
+}
+
+func (p *licenseParser) with() (*licenseExpr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek("WITH") {
+		if p.pos+1 >= len(p.tokens) || isLicenseSyntax(p.tokens[p.pos+1]) {
+			return nil, fmt.Errorf("license: WITH needs an exception")
+		}
+		p.pos += 2
+	}
+	return e, nil
+}
+
+func (p *licenseParser) primary() (*licenseExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("license: expression ends early")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	if token == "(" {
+		e, err := p.parse("OR")
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("license: missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+	if isLicenseSyntax(token) {
+		return nil, fmt.Errorf("license: unexpected %q", token)
+	}
+	return &licenseExpr{id: token}, nil
+}
+
+func isLicenseSyntax(token string) bool {
+	switch strings.ToUpper(token) {
+	case "(", ")", "AND", "OR", "WITH":
+		return true
+	}
+	return false
+}
+
+// licenseFamily maps the versions of an id to one name, so that GPL-3.0,
+// GPL-3.0-only, GPL-3.0-or-later and GPL-3.0+ are the same to a policy.
+func licenseFamily(id string) string {
+	id = strings.ToLower(id)
+	id = strings.TrimSuffix(id, "+")
+	id = strings.TrimSuffix(id, "-only")
+	return strings.TrimSuffix(id, "-or-later")
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version. Missing minor and patch numbers are
+// zero, and build metadata after a "+" is dropped.
+type version struct {
+	nums [3]int
+	pre  []string
+}
+
+func parseVersion(v string) (version, error) {
+	var result version
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if s == "" {
+		return result, fmt.Errorf("empty version")
+	}
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return result, fmt.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return result, fmt.Errorf("invalid version %q", v)
+		}
+		result.nums[i] = n
+	}
+	if hasPre {
+		result.pre = strings.Split(pre, ".")
+		for _, id := range result.pre {
+			if id == "" || strings.Trim(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return result, fmt.Errorf("invalid version %q", v)
+			}
+		}
+	}
+	return result, nil
+}
+
+func ValidVersion(version string) bool {
+	_, err := parseVersion(version)
+	return err == nil
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePre orders pre-releases as semver does: a release comes after its
+// pre-releases, numeric identifiers come before others and compare as
+// numbers, and a longer list wins when one is a prefix of the other. Go
+// pseudo-versions like 0.0.0-20230101120000-abcdef sort by their timestamp.
+func comparePre(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		var c int
+		switch {
+		case errA == nil && errB == nil:
+			c = compareInts(na, nb)
+		case errA == nil:
+			c = -1
+		case errB == nil:
+			c = 1
+		default:
+			c = strings.Compare(a[i], b[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(a), len(b))
+}
+
+// CompareVersions returns -1, 0 or 1. Invalid versions sort before valid ones.
+func CompareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	for i := range va.nums {
+		if c := compareInts(va.nums[i], vb.nums[i]); c != 0 {
+			return c
+		}
+	}
+	return comparePre(va.pre, vb.pre)
+}
+
+type Requirement struct {
+	Name    string
+	Op      string
+	Version string
+}
+
+var requirementOps = []string{"==", ">=", "<=", "!=", ">", "<"}
+
+func ParseRequirement(line string) (Requirement, error) {
+	line = strings.TrimSpace(line)
+	for _, op := range requirementOps {
+		if i := strings.Index(line, op); i >= 0 {
+			req := Requirement{
+				Name:    strings.TrimSpace(line[:i]),
+				Op:      op,
+				Version: strings.TrimSpace(line[i+len(op):]),
+			}
+			if req.Name == "" || !ValidVersion(req.Version) {
+				return Requirement{}, fmt.Errorf("invalid requirement %q", line)
+			}
+			return req, nil
+		}
+	}
+	if line == "" || strings.ContainsAny(line, " \t<>=!") {
+		return Requirement{}, fmt.Errorf("invalid requirement %q", line)
+	}
+	return Requirement{Name: line}, nil
+}
+
+func (r Requirement) String() string {
+	return r.Name + r.Op + r.Version
+}
+
+// Satisfied reports whether version meets the constraint. A package without a
+// known version only satisfies unconstrained requirements.
+func (r Requirement) Satisfied(version string) bool {
+	if r.Op == "" {
+		return true
+	}
+	if !ValidVersion(version) {
+		return false
+	}
+	c := CompareVersions(version, r.Version)
+	switch r.Op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">=":
+		return c >= 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case "<":
+		return c < 0
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync/atomic"
+)
+
+var tempCounter atomic.Uint64
+
+// tempName returns a hidden name next to name for a file that is written
+// before it replaces name.
+func tempName(name string) string {
+	return path.Join(path.Dir(name), fmt.Sprintf(".%s.%d-%d.tmp", path.Base(name), os.Getpid(), tempCounter.Add(1)))
+}
+
+// writeAtomic replaces the content of name with data so that name holds
+// either its old or its new content whatever happens. The data goes to a
+// temporary file that is synced, given the mode of the old file and renamed
+// over name, then the directory is synced so the rename itself is durable.
+func (d *Dir) writeAtomic(name string, data []byte) (err error) {
+	tmp := tempName(name)
+	w, err := d.backend.OpenWriter(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			d.backend.RemoveAll(tmp)
+		}
+	}()
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if err := d.backend.Sync(tmp); err != nil {
+		return err
+	}
+	if info, err := d.backend.Stat(name); err == nil {
+		if err := d.backend.Chmod(tmp, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := d.backend.Replace(tmp, name); err != nil {
+		return err
+	}
+	return d.backend.Sync(path.Dir(name))
+}
+
+// SetDurable makes AppendToFile sync the file to stable storage before it
+// returns. Clones of d keep the setting.
+func (d *Dir) SetDurable(durable bool) {
+	d.durable = durable
+}
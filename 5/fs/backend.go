@@ -0,0 +1,272 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Backend stores the files of a Dir. Names are slash separated and relative
+// to the root of the backend, and must be valid io/fs paths.
+type Backend interface {
+	fs.ReadDirFS
+	fs.ReadFileFS
+	fs.StatFS
+	Create(name string) error
+	Mkdir(name string) error
+	WriteFile(name string, data []byte) error
+	AppendFile(name string, data []byte) error
+	// OpenWriter creates or truncates name and streams writes into it.
+	OpenWriter(name string) (io.WriteCloser, error)
+	// Rename moves oldname to newname, which must not exist yet.
+	Rename(oldname, newname string) error
+	// Replace moves the file oldname over newname in one step. A link at
+	// newname is replaced, not followed.
+	Replace(oldname, newname string) error
+	// Sync flushes the file or directory name to stable storage.
+	Sync(name string) error
+	Chmod(name string, mode fs.FileMode) error
+	RemoveAll(name string) error
+	// New returns an empty backend of the same kind.
+	New() (Backend, error)
+}
+
+// OSBackend keeps files in a directory on disk. Symbolic links leading out
+// of it are refused unless they were made with Symlink.
+type OSBackend struct {
+	root     string
+	realRoot string
+	temp     bool
+	mu       sync.Mutex
+	links    map[string]bool
+}
+
+func NewOS(root string) *OSBackend {
+	root = filepath.Clean(root)
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	return &OSBackend{root: root, realRoot: realRoot, links: make(map[string]bool)}
+}
+
+func validName(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return nil
+}
+
+// path checks name and returns it on disk. Unless follow is set a link as
+// the last element is not followed.
+func (b *OSBackend) path(op, name string, follow bool) (string, error) {
+	if err := validName(op, name); err != nil {
+		return "", err
+	}
+	if err := b.confine(name, follow); err != nil {
+		return "", err
+	}
+	return filepath.Join(b.root, filepath.FromSlash(name)), nil
+}
+
+func (b *OSBackend) linked(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.links[name]
+}
+
+// Symlink makes name a link to target, which may be outside the root.
+func (b *OSBackend) Symlink(name, target string) error {
+	p, err := b.path("symlink", name, false)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, p); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.links[name] = true
+	return nil
+}
+
+func (b *OSBackend) Open(name string) (fs.File, error) {
+	p, err := b.path("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (b *OSBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := b.path("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (b *OSBackend) ReadFile(name string) ([]byte, error) {
+	p, err := b.path("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (b *OSBackend) Stat(name string) (fs.FileInfo, error) {
+	p, err := b.path("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (b *OSBackend) Create(name string) error {
+	p, err := b.path("create", name, true)
+	if err != nil {
+		return err
+	}
+	if err := unshare(p, false); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (b *OSBackend) Mkdir(name string) error {
+	p, err := b.path("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, os.ModePerm)
+}
+
+func (b *OSBackend) WriteFile(name string, data []byte) error {
+	p, err := b.path("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := unshare(p, false); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0644)
+}
+
+func (b *OSBackend) AppendFile(name string, data []byte) error {
+	p, err := b.path("append", name, true)
+	if err != nil {
+		return err
+	}
+	if err := unshare(p, true); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+func (b *OSBackend) OpenWriter(name string) (io.WriteCloser, error) {
+	p, err := b.path("write", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := unshare(p, false); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (b *OSBackend) Rename(oldname, newname string) error {
+	from, err := b.path("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	to, err := b.path("rename", newname, false)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	return os.Rename(from, to)
+}
+
+func (b *OSBackend) Replace(oldname, newname string) error {
+	from, err := b.path("replace", oldname, false)
+	if err != nil {
+		return err
+	}
+	to, err := b.path("replace", newname, false)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (b *OSBackend) Sync(name string) error {
+	p, err := b.path("sync", name, true)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func (b *OSBackend) Chmod(name string, mode fs.FileMode) error {
+	p, err := b.path("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	if err := unshare(p, true); err != nil {
+		return err
+	}
+	return os.Chmod(p, mode)
+}
+
+func (b *OSBackend) RemoveAll(name string) error {
+	p, err := b.path("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(p); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for link := range b.links {
+		if name == "." || link == name || strings.HasPrefix(link, name+"/") {
+			delete(b.links, link)
+		}
+	}
+	return nil
+}
+
+func newTempOS() (*OSBackend, error) {
+	dir, err := os.MkdirTemp("", "vc")
+	if err != nil {
+		return nil, err
+	}
+	b := NewOS(dir)
+	b.temp = true
+	return b, nil
+}
+
+// New returns a backend on a new temporary directory.
+func (b *OSBackend) New() (Backend, error) {
+	return newTempOS()
+}
@@ -0,0 +1,147 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CopyStrategy chooses how Clone and Mount copy files between dirs on disk.
+type CopyStrategy int
+
+const (
+	// CopyDeep copies every file. It is the default.
+	CopyDeep CopyStrategy = iota
+	// CopyHardlink links files instead of copying them. Writes through a Dir
+	// give the file its own copy first, but changes made outside of Dir show
+	// up in every linked tree.
+	CopyHardlink
+	// CopyReflink shares the blocks of files on filesystems that support it,
+	// like btrfs and xfs.
+	CopyReflink
+)
+
+// SetCopyStrategy sets how files are copied into d by Mount and into the
+// clones of d. Strategies other than CopyDeep only apply between dirs on
+// disk, and every file they cannot link is copied.
+func (d *Dir) SetCopyStrategy(strategy CopyStrategy) {
+	d.strategy = strategy
+}
+
+// linkTree is copyTree for two dirs on disk that do not copy deeply. Once
+// linking fails, the rest of the tree is copied without trying again.
+func linkTree(ctx context.Context, srcRoot, dstRoot string, strategy CopyStrategy, ig ignorer) error {
+	return filepath.WalkDir(srcRoot, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcRoot, p)
+		if err != nil {
+			return err
+		}
+		if ig.ignored(filepath.ToSlash(rel), entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		target := filepath.Join(dstRoot, rel)
+		switch {
+		case entry.IsDir():
+			if rel == "." {
+				return nil
+			}
+			return os.Mkdir(target, os.ModePerm)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			linked, err := linkFile(p, target, strategy)
+			if err == nil && !linked {
+				strategy = CopyDeep
+			}
+			return err
+		}
+	})
+}
+
+// linkFile links or copies src to dst and reports whether it could link.
+func linkFile(src, dst string, strategy CopyStrategy) (bool, error) {
+	if strategy == CopyHardlink && os.Link(src, dst) == nil {
+		return true, nil
+	}
+	return copyFile(src, dst, strategy == CopyReflink)
+}
+
+// copyFile copies src to dst, sharing the blocks when clone is set and the
+// filesystem supports it. It reports whether the blocks are shared.
+func copyFile(src, dst string, clone bool) (bool, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return false, err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	if clone && reflink(in, out) == nil {
+		return true, out.Close()
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return false, err
+	}
+	return false, out.Close()
+}
+
+// unshare gives a hardlinked file its own inode before it is written, so the
+// write does not show through the other links. Unless keep is set the old
+// content is not needed and the link is only dropped.
+func unshare(p string, keep bool) error {
+	info, err := os.Lstat(p)
+	if err != nil || !info.Mode().IsRegular() || linkCount(info) < 2 {
+		return nil
+	}
+	if !keep {
+		return os.Remove(p)
+	}
+	// a fixed name could be a sibling that is itself linked into another tree
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".cow-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if _, err := copyFile(p, tmp, false); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// unshareTree unshares every file below root, keeping their content.
+func unshareTree(root string) error {
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		return unshare(p, true)
+	})
+}
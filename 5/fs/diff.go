@@ -0,0 +1,127 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// TreeDiff lists the files that differ between two dirs, sorted by path.
+type TreeDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+
+	a, b *Dir
+}
+
+func (d *TreeDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func (d *Dir) hashFiles() (map[string][sha256.Size]byte, error) {
+	files, err := d.ListFilesIn("")
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string][sha256.Size]byte, len(files))
+	for _, file := range files {
+		content, err := d.backend.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hashes[file] = sha256.Sum256(content)
+	}
+	return hashes, nil
+}
+
+// Diff compares the files of a and b by content. Added files are only in b,
+// removed files only in a.
+func Diff(a, b *Dir) (*TreeDiff, error) {
+	before, err := a.hashFiles()
+	if err != nil {
+		return nil, err
+	}
+	after, err := b.hashFiles()
+	if err != nil {
+		return nil, err
+	}
+	diff := &TreeDiff{Added: []string{}, Removed: []string{}, Modified: []string{}, a: a, b: b}
+	for file, hash := range before {
+		other, ok := after[file]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, file)
+		case other != hash:
+			diff.Modified = append(diff.Modified, file)
+		}
+	}
+	for file := range after {
+		if _, ok := before[file]; !ok {
+			diff.Added = append(diff.Added, file)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff, nil
+}
+
+// splitLines keeps the line endings, adding one to an unterminated last line.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func isText(content string) bool {
+	return utf8.ValidString(content) && !strings.ContainsRune(content, 0)
+}
+
+// Unified renders the changes as a unified diff with three lines of context.
+// Binary files are only named.
+func (d *TreeDiff) Unified() (string, error) {
+	files := append(append(append([]string{}, d.Modified...), d.Added...), d.Removed...)
+	sort.Strings(files)
+	var out bytes.Buffer
+	for _, file := range files {
+		before, after := "", ""
+		fromFile, toFile := "a/"+file, "b/"+file
+		var err error
+		if Contains(d.Added, file) {
+			fromFile = "/dev/null"
+		} else if before, err = d.a.CatFile(file); err != nil {
+			return "", err
+		}
+		if Contains(d.Removed, file) {
+			toFile = "/dev/null"
+		} else if after, err = d.b.CatFile(file); err != nil {
+			return "", err
+		}
+		if !isText(before) || !isText(after) {
+			out.WriteString("Binary files " + fromFile + " and " + toFile + " differ\n")
+			continue
+		}
+		err = difflib.WriteUnifiedDiff(&out, difflib.UnifiedDiff{
+			A:        splitLines(before),
+			B:        splitLines(after),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
@@ -1,61 +1,137 @@
 package fs
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
-	"strings"
+	"time"
 
 	cp "github.com/otiai10/copy"
 )
 
 type Dir struct {
-	d string
+	backend  Backend
+	strategy CopyStrategy
+	durable  bool
 }
 
+// MkDir returns a Dir on a new temporary directory on disk. Close removes it.
 func MkDir() *Dir {
-	dir, err := os.MkdirTemp("", "vc")
+	backend, err := newTempOS()
 	if err != nil {
 		panic(err)
 	}
-	return &Dir{
-		d: dir + string(filepath.Separator),
+	return NewDir(backend)
+}
+
+// Open returns a Dir on an existing directory.
+func Open(path string) (*Dir, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("open %s: not a directory", path)
+	}
+	return NewDir(NewOS(path)), nil
+}
+
+// Create makes path and its parents when missing and returns a Dir on it.
+func Create(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return Open(path)
+}
+
+// MemDir returns an empty Dir kept in memory.
+func MemDir() *Dir {
+	return NewDir(NewMem())
+}
+
+func NewDir(backend Backend) *Dir {
+	return &Dir{backend: backend}
+}
+
+func (d *Dir) Backend() Backend {
+	return d.backend
+}
+
+// Path returns the directory on disk behind d, or "" when d is not on disk.
+func (d *Dir) Path() string {
+	root, _ := d.osRoot()
+	return root
+}
+
+// Close removes d if it is a temporary directory made by MkDir or Clone.
+// Other dirs are left alone.
+func (d *Dir) Close() error {
+	if b, ok := d.backend.(*OSBackend); ok && b.temp {
+		return os.RemoveAll(b.root)
+	}
+	return nil
+}
+
+// RemoveAll deletes d with everything in it, temporary or not.
+func (d *Dir) RemoveAll() error {
+	if root, ok := d.osRoot(); ok {
+		return os.RemoveAll(root)
+	}
+	return d.backend.RemoveAll(".")
+}
+
+// osRoot returns the directory on disk behind d, if there is one.
+func (d *Dir) osRoot() (string, bool) {
+	if b, ok := d.backend.(*OSBackend); ok {
+		return b.root, true
 	}
+	return "", false
 }
 
 func (d *Dir) CreateFile(filename string) error {
-	f, err := os.Create(d.d + filename)
+	name, err := clean(filename)
 	if err != nil {
-		return errors.New("cannot create a file")
+		return err
+	}
+	if err := d.backend.Create(name); err != nil {
+		return fmt.Errorf("cannot create a file: %w", err)
 	}
-	defer f.Close()
 	return nil
 }
 
 func (d *Dir) CreateDir(dirname string) error {
-	err := os.Mkdir(d.d+dirname, os.ModePerm)
+	name, err := clean(dirname)
 	if err != nil {
 		return err
 	}
-	return nil
+	return d.backend.Mkdir(name)
 }
 
+// ListFilesIn lists the files below dir, leaving out those ignored by
+// IgnoreFile.
 func (d *Dir) ListFilesIn(dir string) ([]string, error) {
+	name, err := clean(dir)
+	if err != nil {
+		return nil, err
+	}
 	var files []string
-	err := filepath.Walk(d.d+dir, func(path string, info fs.FileInfo, err error) error {
+	err = d.walk(name, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			myPath := strings.TrimPrefix(path, d.d)
-			myPath = strings.ReplaceAll(myPath, "\\", "/") // Normalize path separators
-			files = append(files, myPath)
+		if !entry.IsDir() {
+			files = append(files, path)
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, errors.New("cannot list files in dir")
+		return nil, fmt.Errorf("cannot list files in dir: %w", err)
 	}
 	return files, nil
 }
@@ -69,9 +145,13 @@ func (d *Dir) ListFilesRoot() []string {
 }
 
 func (d *Dir) CatFile(file string) (string, error) {
-	content, err := os.ReadFile(d.d + file)
+	name, err := clean(file)
+	if err != nil {
+		return "", err
+	}
+	content, err := d.backend.ReadFile(name)
 	if err != nil {
-		return "", errors.New("cannot read the file")
+		return "", fmt.Errorf("cannot read the file: %w", err)
 	}
 	return string(content), nil
 }
@@ -85,58 +165,223 @@ func Contains(list []string, elem string) bool {
 	return false
 }
 
+func (d *Dir) isFile(name string) bool {
+	info, err := d.backend.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// WriteToFile replaces the content of an existing file atomically, so an
+// interrupted write leaves the old content behind.
 func (d *Dir) WriteToFile(file string, content string) error {
-	if !Contains(d.ListFilesRoot(), file) {
+	name, err := clean(file)
+	if err != nil {
+		return err
+	}
+	if !d.isFile(name) {
 		return errors.New("files does not exist")
 	}
-	err := os.WriteFile(d.d+file, []byte(content), os.ModePerm)
-	if err != nil {
-		panic(err)
+	if err := d.writeAtomic(name, []byte(content)); err != nil {
+		return fmt.Errorf("cannot write the file: %w", err)
 	}
 	return nil
 }
 
 func (d *Dir) AppendToFile(file, content string) error {
-	if !Contains(d.ListFilesRoot(), file) {
+	name, err := clean(file)
+	if err != nil {
+		return err
+	}
+	if !d.isFile(name) {
 		return errors.New("file does not exist")
 	}
-	f, err := os.OpenFile(d.d+file, os.O_APPEND|os.O_WRONLY, 0600)
-	if err != nil {
-		panic(err)
+	if err := d.backend.AppendFile(name, []byte(content)); err != nil {
+		return fmt.Errorf("cannot append to the file: %w", err)
 	}
-	defer f.Close()
+	if d.durable {
+		return d.backend.Sync(name)
+	}
+	return nil
+}
 
-	_, err = f.WriteString(content)
+// Clone copies d into a new Dir of the same kind.
+func (d *Dir) Clone() *Dir {
+	cwd, err := d.CloneContext(context.Background())
 	if err != nil {
 		panic(err)
 	}
+	return cwd
+}
+
+// copyOptions aborts a copy at the next file once ctx is done and skips the
+// files ignored in srcRoot.
+func copyOptions(ctx context.Context, srcRoot string, ig ignorer) cp.Options {
+	return cp.Options{
+		Skip: func(info os.FileInfo, src, _ string) (bool, error) {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			rel, err := filepath.Rel(srcRoot, src)
+			if err != nil {
+				return false, err
+			}
+			return ig.ignored(filepath.ToSlash(rel), info.IsDir()), nil
+		},
+	}
+}
 
-	return nil
+// copyTree copies every file of src below root in d, leaving out those
+// ignored in src. Copies between two directories on disk follow the copy
+// strategy of d, copies between two memory backends share the files,
+// anything else goes through the backends.
+func (d *Dir) copyTree(ctx context.Context, root string, src *Dir) error {
+	ig, err := src.ignorer()
+	if err != nil {
+		return err
+	}
+	dstRoot, dstOK := d.osRoot()
+	srcRoot, srcOK := src.osRoot()
+	if dstOK && srcOK {
+		target := filepath.Join(dstRoot, filepath.FromSlash(root))
+		if d.strategy != CopyDeep {
+			return linkTree(ctx, srcRoot, target, d.strategy, ig)
+		}
+		return cp.Copy(srcRoot, target, copyOptions(ctx, srcRoot, ig))
+	}
+	dstMem, dstOK := d.backend.(*MemBackend)
+	srcMem, srcOK := src.backend.(*MemBackend)
+	if dstOK && srcOK {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return dstMem.share(srcMem, root, ig)
+	}
+	return src.walk(".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target := path.Join(root, name)
+		if entry.IsDir() {
+			if name == "." {
+				return nil
+			}
+			return d.backend.Mkdir(target)
+		}
+		data, err := src.backend.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return d.backend.WriteFile(target, data)
+	})
 }
 
-func (d *Dir) Clone() *Dir {
-	new_Dir, err := os.MkdirTemp("", "vc")
+func (d *Dir) CloneContext(ctx context.Context) (*Dir, error) {
+	backend, err := d.backend.New()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	cwd := &Dir{
-		d: new_Dir + "/",
+	cwd := NewDir(backend)
+	cwd.strategy = d.strategy
+	cwd.durable = d.durable
+	if err := cwd.copyTree(ctx, ".", d); err != nil {
+		backend.RemoveAll(".")
+		return nil, err
 	}
-	cp.Copy(d.d, cwd.d)
-	return cwd
+	return cwd, nil
 }
 
 func (d *Dir) Mount(path string, other *Dir) error {
-	if err := d.CreateDir(path); err != nil {
+	return d.MountContext(context.Background(), path, other)
+}
+
+// MountContext copies other into path. A cancelled mount removes whatever
+// was already copied.
+func (d *Dir) MountContext(ctx context.Context, path string, other *Dir) error {
+	name, err := clean(path)
+	if err != nil {
 		return err
 	}
-	if err := cp.Copy(other.d, d.d+path); err != nil {
+	if err := d.backend.Mkdir(name); err != nil {
+		return err
+	}
+	if err := d.copyTree(ctx, name, other); err != nil {
+		d.backend.RemoveAll(name)
 		return err
 	}
 	return nil
+}
 
+// Link makes path a symbolic link to the root of other instead of copying it.
+// Both dirs must be on disk.
+func (d *Dir) Link(path string, other *Dir) error {
+	name, err := clean(path)
+	if err != nil {
+		return err
+	}
+	b, ok := d.backend.(*OSBackend)
+	target, otherOK := other.osRoot()
+	if !ok || !otherOK {
+		return fmt.Errorf("link %s: %w", path, errors.ErrUnsupported)
+	}
+	return b.Symlink(name, target)
 }
 
+// Remove deletes path and everything below it. A link is removed, not what
+// it points to.
 func (d *Dir) Remove(path string) error {
-	return os.RemoveAll(d.d + path)
+	name, err := clean(path)
+	if err != nil {
+		return err
+	}
+	return d.backend.RemoveAll(name)
+}
+
+// Run executes a command inside dir with a minimal environment whose HOME and
+// TMPDIR also point at dir. The command is confined with landlock: it can read
+// and execute files anywhere, but only change files below dir. Where it cannot
+// be confined, on other systems or kernels without landlock, Run refuses to
+// run it. It returns the combined output. Only dirs on disk can run commands.
+// With CopyHardlink the files below dir are unshared first.
+func (d *Dir) Run(ctx context.Context, dir string, name string, args ...string) (string, error) {
+	b, ok := d.backend.(*OSBackend)
+	if !ok {
+		return "", fmt.Errorf("run %s: %w", name, errors.ErrUnsupported)
+	}
+	rel, err := clean(dir)
+	if err != nil {
+		return "", err
+	}
+	wd, err := b.path("run", rel, true)
+	if err != nil {
+		return "", err
+	}
+	// the command writes past Dir, so linked files get their own copy first
+	if d.strategy == CopyHardlink {
+		if err := unshareTree(wd); err != nil {
+			return "", err
+		}
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = wd
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + wd,
+		"TMPDIR=" + wd,
+		"PWD=" + wd,
+	}
+	killProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := startConfined(cmd, wd); err != nil {
+		return "", fmt.Errorf("run %s confined: %w", name, err)
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return out.String(), ctx.Err()
+	}
+	return out.String(), err
 }
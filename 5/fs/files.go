@@ -0,0 +1,141 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+func (d *Dir) Stat(file string) (fs.FileInfo, error) {
+	name, err := clean(file)
+	if err != nil {
+		return nil, err
+	}
+	return d.backend.Stat(name)
+}
+
+// Rename gives file a new name in the same directory.
+func (d *Dir) Rename(file, newName string) error {
+	if newName == "" || newName == "." || newName == ".." || path.Base(newName) != newName {
+		return fmt.Errorf("rename %s: bad name %q", file, newName)
+	}
+	name, err := clean(file)
+	if err != nil {
+		return err
+	}
+	return d.backend.Rename(name, path.Join(path.Dir(name), newName))
+}
+
+// Move moves a file or directory to another path of d, making missing
+// parents. The target must not exist.
+func (d *Dir) Move(from, to string) error {
+	src, err := clean(from)
+	if err != nil {
+		return err
+	}
+	dst, err := clean(to)
+	if err != nil {
+		return err
+	}
+	if err := d.makeParents(dst); err != nil {
+		return err
+	}
+	return d.backend.Rename(src, dst)
+}
+
+func (d *Dir) makeParents(name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	if err := d.makeParents(parent); err != nil {
+		return err
+	}
+	if err := d.backend.Mkdir(parent); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+func (d *Dir) Chmod(file string, mode fs.FileMode) error {
+	name, err := clean(file)
+	if err != nil {
+		return err
+	}
+	return d.backend.Chmod(name, mode)
+}
+
+func (d *Dir) ReadBytes(file string) ([]byte, error) {
+	name, err := clean(file)
+	if err != nil {
+		return nil, err
+	}
+	return d.backend.ReadFile(name)
+}
+
+// WriteBytes creates file or replaces its content atomically. Unlike
+// WriteToFile the file does not have to exist.
+func (d *Dir) WriteBytes(file string, data []byte) error {
+	name, err := clean(file)
+	if err != nil {
+		return err
+	}
+	return d.writeAtomic(name, data)
+}
+
+// Open streams the content of file.
+func (d *Dir) Open(file string) (io.ReadCloser, error) {
+	name, err := clean(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := d.backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("open %s: is a directory", file)
+	}
+	return f, nil
+}
+
+// OpenWriter creates or truncates file and streams writes into it. The
+// content is complete once the writer is closed.
+func (d *Dir) OpenWriter(file string) (io.WriteCloser, error) {
+	name, err := clean(file)
+	if err != nil {
+		return nil, err
+	}
+	return d.backend.OpenWriter(name)
+}
+
+// ListDirsIn lists every directory below dir, not dir itself. Ignored
+// directories are left out.
+func (d *Dir) ListDirsIn(dir string) ([]string, error) {
+	root, err := clean(dir)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]string, 0)
+	err = d.walk(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && name != root {
+			dirs = append(dirs, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list dirs in dir: %w", err)
+	}
+	return dirs, nil
+}
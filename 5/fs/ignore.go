@@ -0,0 +1,160 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// IgnoreFile lists the paths that listing, Clone, Mount and packing leave
+// out, with the syntax of .gitignore. Only the file at the root of a Dir is
+// read, and patterns are relative to that root.
+const IgnoreFile = ".gopiignore"
+
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// ignorer holds the rules of an ignore file. The last rule matching a path
+// decides whether it is ignored.
+type ignorer []ignoreRule
+
+func parseIgnore(content string) ignorer {
+	var rules ignorer
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var rule ignoreRule
+		switch {
+		case line[0] == '!':
+			rule.negate = true
+			line = line[1:]
+		case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// a pattern without a slash, other than a trailing one, matches at any depth
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		rule.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matchSegments matches a path split at slashes against pattern segments,
+// where "**" stands for any number of segments.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], name[0])
+	return err == nil && ok && matchSegments(pattern[1:], name[1:])
+}
+
+// matches reports whether the rules ignore name, not looking at its parents.
+func (ig ignorer) matches(name string, isDir bool) bool {
+	ignored := false
+	segments := strings.Split(name, "/")
+	for _, rule := range ig {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(rule.segments, segments) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// ignored reports whether name is ignored by itself or through one of its
+// parents. As in git, a file cannot be brought back once its dir is ignored.
+func (ig ignorer) ignored(name string, isDir bool) bool {
+	if len(ig) == 0 || name == "." {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && ig.matches(name[:i], true) {
+			return true
+		}
+	}
+	return ig.matches(name, isDir)
+}
+
+// ignorer reads the ignore file of d. A missing file ignores nothing.
+func (d *Dir) ignorer() (ignorer, error) {
+	content, err := d.backend.ReadFile(IgnoreFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseIgnore(string(content)), nil
+}
+
+// walk is fs.WalkDir from root, leaving out what the ignore file of d lists.
+func (d *Dir) walk(root string, fn fs.WalkDirFunc) error {
+	ig, err := d.ignorer()
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(d.backend, root, func(name string, entry fs.DirEntry, err error) error {
+		if err == nil && ig.ignored(name, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(name, entry, err)
+	})
+}
+
+// Glob returns the files of d matching pattern, sorted. Besides the syntax of
+// path.Match, a "**" segment matches any number of directories. Ignored files
+// are left out.
+func (d *Dir) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return nil, err
+	}
+	segments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	matches := make([]string, 0)
+	err := d.walk(".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && matchSegments(segments, strings.Split(name, "/")) {
+			matches = append(matches, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
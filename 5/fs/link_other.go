@@ -0,0 +1,13 @@
+//go:build !unix
+
+package fs
+
+import "os"
+
+func linkCount(info os.FileInfo) uint64 {
+	return 1
+}
+
+func fileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
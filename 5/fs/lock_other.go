@@ -0,0 +1,19 @@
+//go:build !unix
+
+package fs
+
+import "context"
+
+// Lock is a no-op on platforms without flock.
+func (d *Dir) Lock() (func(), error) {
+	return func() {}, nil
+}
+
+// LockContext is a no-op on platforms without flock, failing only once ctx
+// is done.
+func (d *Dir) LockContext(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
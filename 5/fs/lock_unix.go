@@ -0,0 +1,60 @@
+//go:build unix
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// lockPoll is how often LockContext tries a lock held by someone else again.
+const lockPoll = 10 * time.Millisecond
+
+// Lock takes an exclusive flock on the directory itself, blocking until other
+// holders, in this or another process, release it. Dirs that are not on disk
+// cannot be shared with other processes and are not locked.
+func (d *Dir) Lock() (func(), error) {
+	return d.LockContext(context.Background())
+}
+
+// LockContext is Lock that stops waiting with ctx.Err() once ctx is done.
+func (d *Dir) LockContext(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	root, ok := d.osRoot()
+	if !ok {
+		return func() {}, nil
+	}
+	f, err := os.Open(root)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_EX
+	if ctx.Done() != nil {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,375 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemBackend keeps files in memory. It is safe for concurrent use.
+type MemBackend struct {
+	mu sync.RWMutex
+	// nodes are replaced on every write and never modified in place, so
+	// readers may keep them after unlocking
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func NewMem() *MemBackend {
+	return &MemBackend{
+		nodes: map[string]*memNode{
+			".": {mode: fs.ModeDir | 0777, modTime: time.Now()},
+		},
+	}
+}
+
+type memInfo struct {
+	name string
+	node *memNode
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i memInfo) ModTime() time.Time { return i.node.modTime }
+func (i memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	info    memInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// stat must be called with the lock held.
+func (b *MemBackend) stat(op, name string) (memInfo, error) {
+	node, ok := b.nodes[name]
+	if !ok {
+		return memInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: path.Base(name), node: node}, nil
+}
+
+func (b *MemBackend) children(name string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0)
+	for p, node := range b.nodes {
+		if p != "." && path.Dir(p) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: path.Base(p), node: node}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+func (b *MemBackend) Open(name string) (fs.File, error) {
+	if err := validName("open", name); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	info, err := b.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &memDir{info: info, entries: b.children(name)}, nil
+	}
+	return &memFile{Reader: bytes.NewReader(info.node.data), info: info}, nil
+}
+
+func (b *MemBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := validName("readdir", name); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	info, err := b.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return b.children(name), nil
+}
+
+func (b *MemBackend) ReadFile(name string) ([]byte, error) {
+	if err := validName("read", name); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	info, err := b.stat("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return bytes.Clone(info.node.data), nil
+}
+
+func (b *MemBackend) Stat(name string) (fs.FileInfo, error) {
+	if err := validName("stat", name); err != nil {
+		return nil, err
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	info, err := b.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// put must be called with the write lock held.
+func (b *MemBackend) put(op, name string, node *memNode) error {
+	parent, ok := b.nodes[path.Dir(name)]
+	if !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if old, ok := b.nodes[name]; ok && (old.mode.IsDir() || node.mode.IsDir()) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	b.nodes[name] = node
+	return nil
+}
+
+func (b *MemBackend) Create(name string) error {
+	return b.WriteFile(name, nil)
+}
+
+// mode keeps the mode of an existing file. It must be called with the lock
+// held.
+func (b *MemBackend) mode(name string) fs.FileMode {
+	if old, ok := b.nodes[name]; ok {
+		return old.mode
+	}
+	return 0644
+}
+
+func (b *MemBackend) Mkdir(name string) error {
+	if err := validName("mkdir", name); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.put("mkdir", name, &memNode{mode: fs.ModeDir | 0777, modTime: time.Now()})
+}
+
+func (b *MemBackend) WriteFile(name string, data []byte) error {
+	if err := validName("write", name); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.put("write", name, &memNode{data: bytes.Clone(data), mode: b.mode(name), modTime: time.Now()})
+}
+
+func (b *MemBackend) AppendFile(name string, data []byte) error {
+	if err := validName("append", name); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	node, ok := b.nodes[name]
+	if !ok || node.mode.IsDir() {
+		return &fs.PathError{Op: "append", Path: name, Err: fs.ErrNotExist}
+	}
+	b.nodes[name] = &memNode{
+		data:    append(bytes.Clone(node.data), data...),
+		mode:    node.mode,
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+type memWriter struct {
+	b    *MemBackend
+	name string
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Close stores what was written. Until then the file is empty.
+func (w *memWriter) Close() error {
+	return w.b.WriteFile(w.name, w.buf.Bytes())
+}
+
+func (b *MemBackend) OpenWriter(name string) (io.WriteCloser, error) {
+	if err := b.WriteFile(name, nil); err != nil {
+		return nil, err
+	}
+	return &memWriter{b: b, name: name}, nil
+}
+
+func (b *MemBackend) Rename(oldname, newname string) error {
+	for _, name := range []string{oldname, newname} {
+		if err := validName("rename", name); err != nil {
+			return err
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.nodes[oldname]; !ok || oldname == "." {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if _, ok := b.nodes[newname]; ok {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	if parent, ok := b.nodes[path.Dir(newname)]; !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrNotExist}
+	}
+	if strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	moved := make(map[string]*memNode)
+	for p, node := range b.nodes {
+		if p == oldname || strings.HasPrefix(p, oldname+"/") {
+			moved[newname+strings.TrimPrefix(p, oldname)] = node
+			delete(b.nodes, p)
+		}
+	}
+	for p, node := range moved {
+		b.nodes[p] = node
+	}
+	return nil
+}
+
+func (b *MemBackend) Replace(oldname, newname string) error {
+	for _, name := range []string{oldname, newname} {
+		if err := validName("replace", name); err != nil {
+			return err
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	node, ok := b.nodes[oldname]
+	if !ok || node.mode.IsDir() {
+		return &fs.PathError{Op: "replace", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if old, ok := b.nodes[newname]; ok && old.mode.IsDir() {
+		return &fs.PathError{Op: "replace", Path: newname, Err: fs.ErrExist}
+	}
+	if parent, ok := b.nodes[path.Dir(newname)]; !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: "replace", Path: newname, Err: fs.ErrNotExist}
+	}
+	b.nodes[newname] = node
+	delete(b.nodes, oldname)
+	return nil
+}
+
+// Sync has nothing to flush, it only checks that name exists.
+func (b *MemBackend) Sync(name string) error {
+	_, err := b.Stat(name)
+	return err
+}
+
+func (b *MemBackend) Chmod(name string, mode fs.FileMode) error {
+	if err := validName("chmod", name); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	node, ok := b.nodes[name]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrNotExist}
+	}
+	b.nodes[name] = &memNode{
+		data:    node.data,
+		mode:    node.mode.Type() | mode.Perm(),
+		modTime: node.modTime,
+	}
+	return nil
+}
+
+// RemoveAll removes name and everything below it. Removing the root only
+// empties it.
+func (b *MemBackend) RemoveAll(name string) error {
+	if err := validName("remove", name); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for p := range b.nodes {
+		if p == "." {
+			continue
+		}
+		if name == "." || p == name || strings.HasPrefix(p, name+"/") {
+			delete(b.nodes, p)
+		}
+	}
+	return nil
+}
+
+// share adds the files of src below root. Nodes are never modified in place,
+// so they are shared instead of copied.
+func (b *MemBackend) share(src *MemBackend, root string, ig ignorer) error {
+	src.mu.RLock()
+	nodes := make(map[string]*memNode, len(src.nodes))
+	for p, node := range src.nodes {
+		if !ig.ignored(p, node.mode.IsDir()) {
+			nodes[p] = node
+		}
+	}
+	src.mu.RUnlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if parent, ok := b.nodes[root]; !ok || !parent.mode.IsDir() {
+		return &fs.PathError{Op: "copy", Path: root, Err: fs.ErrNotExist}
+	}
+	for p, node := range nodes {
+		if p != "." {
+			b.nodes[path.Join(root, p)] = node
+		}
+	}
+	return nil
+}
+
+func (b *MemBackend) New() (Backend, error) {
+	return NewMem(), nil
+}
@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutsideRoot is returned for paths that would leave the root of a Dir,
+// either with ".." and absolute paths or through a symbolic link.
+type ErrOutsideRoot struct {
+	Path string
+}
+
+func (e *ErrOutsideRoot) Error() string {
+	return "path " + e.Path + " is outside the root"
+}
+
+// clean turns a Dir path into a backend name confined to the root.
+func clean(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", &ErrOutsideRoot{Path: name}
+	}
+	cleaned := path.Clean(slashed)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", &ErrOutsideRoot{Path: name}
+	}
+	return cleaned, nil
+}
+
+func within(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// confine checks that no symbolic link on the way to name points outside the
+// root, except the links made by Symlink. The last element is only checked
+// when follow is set, so that a link itself can always be removed.
+func (b *OSBackend) confine(name string, follow bool) error {
+	if name == "." {
+		return nil
+	}
+	parts := strings.Split(name, "/")
+	cur := b.root
+	for i, part := range parts {
+		if i == len(parts)-1 && !follow {
+			return nil
+		}
+		cur = filepath.Join(cur, part)
+		info, err := os.Lstat(cur)
+		if err != nil {
+			// nothing below a missing element can be a link
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		if b.linked(path.Join(parts[:i+1]...)) {
+			return nil
+		}
+		target, err := filepath.EvalSymlinks(cur)
+		if err != nil {
+			// a dangling link is judged by where it points
+			if target, err = os.Readlink(cur); err != nil {
+				return err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(cur), target)
+			}
+			if !within(b.root, target) && !within(b.realRoot, target) {
+				return &ErrOutsideRoot{Path: name}
+			}
+			return nil
+		}
+		if !within(b.realRoot, target) {
+			return &ErrOutsideRoot{Path: name}
+		}
+	}
+	return nil
+}
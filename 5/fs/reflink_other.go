@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fs
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(in, out *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package fs
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup makes cancellation kill every process the command spawned,
+// not only the direct child.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package fs
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func startConfined(cmd *exec.Cmd, wd string) error {
+	return errors.ErrUnsupported
+}
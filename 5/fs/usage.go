@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// Usage is what a tree takes on disk. Ignored files count too, since they
+// take space all the same, but links below the measured path are not
+// followed.
+type Usage struct {
+	Files int
+	// Bytes sums the sizes of the files.
+	Bytes int64
+	// Shared is the part of Bytes in files with other hard links, inside the
+	// tree or outside of it.
+	Shared int64
+	// Disk counts files hardlinked within the tree once.
+	Disk int64
+}
+
+// Unique is the part of Bytes that only this tree uses.
+func (u Usage) Unique() int64 {
+	return u.Bytes - u.Shared
+}
+
+type inode struct {
+	dev, ino uint64
+}
+
+// Usage measures the files at or below path.
+func (d *Dir) Usage(path string) (Usage, error) {
+	root, err := clean(path)
+	if err != nil {
+		return Usage{}, err
+	}
+	var usage Usage
+	seen := make(map[inode]bool)
+	err = fs.WalkDir(d.backend, root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		if linkCount(info) < 2 {
+			usage.Disk += info.Size()
+			return nil
+		}
+		usage.Shared += info.Size()
+		if dev, ino, ok := fileID(info); ok {
+			if seen[inode{dev, ino}] {
+				return nil
+			}
+			seen[inode{dev, ino}] = true
+		}
+		usage.Disk += info.Size()
+		return nil
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("cannot measure dir: %w", err)
+	}
+	return usage, nil
+}
+
+// Size returns the bytes of the files at or below path.
+func (d *Dir) Size(path string) (int64, error) {
+	usage, err := d.Usage(path)
+	return usage.Bytes, err
+}
+
+// CountFiles returns how many files are at or below path.
+func (d *Dir) CountFiles(path string) (int, error) {
+	usage, err := d.Usage(path)
+	return usage.Files, err
+}
@@ -0,0 +1,226 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+type EventOp int
+
+const (
+	Created EventOp = iota
+	Modified
+	Removed
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event tells that the file at Path changed.
+type Event struct {
+	Path string
+	Op   EventOp
+}
+
+const (
+	// watchDebounce is how long a tree must stay quiet before the changes
+	// made to it are delivered.
+	watchDebounce = 100 * time.Millisecond
+	// watchMaxWait bounds how long changes wait for the tree to be quiet, so
+	// a file that keeps changing is still reported.
+	watchMaxWait = time.Second
+	// watchPoll is how often the tree is scanned when changes cannot be
+	// watched natively.
+	watchPoll = 250 * time.Millisecond
+)
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func stateOf(info fs.FileInfo) fileState {
+	return fileState{size: info.Size(), modTime: info.ModTime(), mode: info.Mode()}
+}
+
+func (s fileState) same(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime) && s.mode == other.mode
+}
+
+type watcher struct {
+	d     *Dir
+	known map[string]fileState
+	dirty map[string]bool
+}
+
+// Watch reports changes to the files of d until ctx is done, then closes the
+// channel. Changes are debounced: once the tree has been quiet for a moment,
+// or at the latest a second after the first change, they are delivered as one
+// batch, with an event per file sorted by path.
+// Events name files, never directories, and ignored files are left out.
+// Dirs on disk are watched with inotify on Linux, other dirs are polled.
+func (d *Dir) Watch(ctx context.Context) <-chan []Event {
+	w := &watcher{d: d, known: d.scan("."), dirty: make(map[string]bool)}
+	changes := make(chan string)
+	var poll <-chan time.Time
+	var closer io.Closer
+	if b, ok := d.backend.(*OSBackend); ok {
+		closer, _ = notify(ctx, b.root, changes)
+	}
+	if closer == nil {
+		ticker := time.NewTicker(watchPoll)
+		poll = ticker.C
+		closer = tickerCloser{ticker}
+	}
+	events := make(chan []Event)
+	go func() {
+		defer close(events)
+		defer closer.Close()
+		w.run(ctx, changes, poll, events)
+	}()
+	return events
+}
+
+type tickerCloser struct {
+	*time.Ticker
+}
+
+func (t tickerCloser) Close() error {
+	t.Stop()
+	return nil
+}
+
+func (w *watcher) run(ctx context.Context, changes <-chan string, poll <-chan time.Time, events chan<- []Event) {
+	// flush fires once the tree is quiet, deadline once the first pending
+	// change has waited for watchMaxWait
+	var flush, deadline <-chan time.Time
+	pending := func() {
+		flush = time.After(watchDebounce)
+		if deadline == nil {
+			deadline = time.After(watchMaxWait)
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-changes:
+			w.dirty[name] = true
+			pending()
+			continue
+		case <-poll:
+			if w.changed() {
+				pending()
+			}
+			continue
+		case <-flush:
+		case <-deadline:
+		}
+		flush, deadline = nil, nil
+		batch := w.flush()
+		if len(batch) == 0 {
+			continue
+		}
+		select {
+		case events <- batch:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scan returns the state of the files below root.
+func (d *Dir) scan(root string) map[string]fileState {
+	files := make(map[string]fileState)
+	d.walk(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := d.backend.Stat(name); err == nil && info.Mode().IsRegular() {
+			files[name] = stateOf(info)
+		}
+		return nil
+	})
+	return files
+}
+
+// changed marks the files that differ from the last known state of the tree.
+func (w *watcher) changed() bool {
+	current := w.d.scan(".")
+	found := false
+	for name, state := range current {
+		if old, ok := w.known[name]; !ok || !old.same(state) {
+			w.dirty[name] = true
+			found = true
+		}
+	}
+	for name := range w.known {
+		if _, ok := current[name]; !ok {
+			w.dirty[name] = true
+			found = true
+		}
+	}
+	return found
+}
+
+// flush compares every file at or below a dirty path with its last known
+// state. A file made and removed again between two flushes is not reported.
+func (w *watcher) flush() []Event {
+	candidates := make(map[string]bool)
+	for name := range w.dirty {
+		prefix := name + "/"
+		if name == "." {
+			prefix = ""
+		} else {
+			candidates[name] = true
+		}
+		for known := range w.known {
+			if strings.HasPrefix(known, prefix) {
+				candidates[known] = true
+			}
+		}
+		if info, err := w.d.backend.Stat(name); err == nil && info.IsDir() {
+			for file := range w.d.scan(name) {
+				candidates[file] = true
+			}
+		}
+	}
+	w.dirty = make(map[string]bool)
+
+	ig, _ := w.d.ignorer()
+	var batch []Event
+	for name := range candidates {
+		old, known := w.known[name]
+		info, err := w.d.backend.Stat(name)
+		exists := err == nil && info.Mode().IsRegular() && !ig.ignored(name, false)
+		switch {
+		case exists && !known:
+			batch = append(batch, Event{Path: name, Op: Created})
+		case exists && !old.same(stateOf(info)):
+			batch = append(batch, Event{Path: name, Op: Modified})
+		case !exists && known:
+			batch = append(batch, Event{Path: name, Op: Removed})
+		}
+		if exists {
+			w.known[name] = stateOf(info)
+		} else {
+			delete(w.known, name)
+		}
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].Path < batch[j].Path })
+	return batch
+}
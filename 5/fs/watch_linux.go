@@ -0,0 +1,103 @@
+//go:build linux
+
+package fs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB |
+	unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotify watches every directory of a tree on disk. Only its read loop
+// touches dirs once it runs.
+type inotify struct {
+	fd   int
+	file *os.File
+	root string
+	dirs map[int32]string
+}
+
+// notify sends the names of the paths changing below root on changes until
+// the returned closer is closed.
+func notify(ctx context.Context, root string, changes chan<- string) (io.Closer, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// a non blocking file goes through the runtime poller, so Close stops Read
+	n := &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), root: root, dirs: make(map[int32]string)}
+	if err := n.addTree("."); err != nil {
+		n.file.Close()
+		return nil, err
+	}
+	go n.read(ctx, changes)
+	return n.file, nil
+}
+
+// addTree watches dir and every directory below it.
+func (n *inotify) addTree(dir string) error {
+	return filepath.WalkDir(filepath.Join(n.root, filepath.FromSlash(dir)), func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(n.root, p)
+		if err != nil {
+			return err
+		}
+		wd, err := unix.InotifyAddWatch(n.fd, p, inotifyMask)
+		if err != nil {
+			return err
+		}
+		n.dirs[int32(wd)] = filepath.ToSlash(rel)
+		return nil
+	})
+}
+
+func (n *inotify) read(ctx context.Context, changes chan<- string) {
+	buf := make([]byte, 64<<10)
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= size; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			name := "."
+			switch {
+			case event.Mask&unix.IN_Q_OVERFLOW != 0:
+				// events were lost, everything has to be looked at again
+			case event.Mask&unix.IN_IGNORED != 0:
+				delete(n.dirs, event.Wd)
+				continue
+			default:
+				dir, ok := n.dirs[event.Wd]
+				if !ok {
+					continue
+				}
+				name = path.Join(dir, unix.ByteSliceToString(nameBytes))
+			}
+			// files made in a new directory before it is watched are picked
+			// up when the directory itself is looked at
+			if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				n.addTree(name)
+			}
+			select {
+			case changes <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
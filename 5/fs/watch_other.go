@@ -0,0 +1,13 @@
+//go:build !linux
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+func notify(ctx context.Context, root string, changes chan<- string) (io.Closer, error) {
+	return nil, errors.ErrUnsupported
+}
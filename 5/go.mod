@@ -1,19 +1,20 @@
 module pip
 
-go 1.20
+go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/lithammer/fuzzysearch v1.1.8
 	github.com/otiai10/copy v1.14.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.8.2
+	golang.org/x/sys v0.24.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/otiai10/mint v1.6.3 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
-	golang.org/x/sys v0.24.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
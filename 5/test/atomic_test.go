@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errCrash = errors.New("crash")
+
+// faultyBackend fails the operations named in fail, as a crash or a full
+// disk would.
+type faultyBackend struct {
+	fs.Backend
+	fail  map[string]bool
+	syncs []string
+}
+
+func newFaulty(fail ...string) *faultyBackend {
+	b := &faultyBackend{Backend: fs.NewMem(), fail: make(map[string]bool)}
+	for _, op := range fail {
+		b.fail[op] = true
+	}
+	return b
+}
+
+// halfWriter writes half of the first chunk and fails.
+type halfWriter struct {
+	io.WriteCloser
+}
+
+func (w halfWriter) Write(p []byte) (int, error) {
+	n, _ := w.WriteCloser.Write(p[:len(p)/2])
+	return n, errCrash
+}
+
+func (b *faultyBackend) OpenWriter(name string) (io.WriteCloser, error) {
+	w, err := b.Backend.OpenWriter(name)
+	if err != nil || !b.fail["write"] {
+		return w, err
+	}
+	return halfWriter{w}, nil
+}
+
+func (b *faultyBackend) Sync(name string) error {
+	if b.fail["sync"] {
+		return errCrash
+	}
+	b.syncs = append(b.syncs, name)
+	return b.Backend.Sync(name)
+}
+
+func (b *faultyBackend) Replace(oldname, newname string) error {
+	if b.fail["replace"] {
+		return errCrash
+	}
+	return b.Backend.Replace(oldname, newname)
+}
+
+func (b *faultyBackend) RemoveAll(name string) error {
+	if b.fail["remove"] {
+		return errCrash
+	}
+	return b.Backend.RemoveAll(name)
+}
+
+func TestWriteInterrupted(t *testing.T) {
+	for _, fail := range [][]string{{"write"}, {"sync"}, {"replace"}} {
+		b := newFaulty()
+		dir := withFile(fs.NewDir(b), "metadata.toml", "version = \"1.0\"\n")
+		for _, op := range fail {
+			b.fail[op] = true
+		}
+		err := dir.WriteToFile("metadata.toml", "version = \"2.0\"\n")
+		assert.ErrorIs(t, err, errCrash, fail)
+		content, err := dir.CatFile("metadata.toml")
+		assert.NoError(t, err)
+		assert.Equal(t, "version = \"1.0\"\n", content, fail)
+		// the temporary file is cleaned up
+		assert.Equal(t, []string{"metadata.toml"}, dir.ListFilesRoot(), fail)
+	}
+}
+
+func TestWriteCrashLeavesOldContent(t *testing.T) {
+	b := newFaulty()
+	dir := withFile(fs.NewDir(b), "metadata.toml", "old")
+	b.fail["replace"] = true
+	b.fail["remove"] = true
+	assert.Error(t, dir.WriteToFile("metadata.toml", "new"))
+	content, err := dir.CatFile("metadata.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "old", content)
+	// only the hidden temporary file is left over
+	assert.Len(t, dir.ListFilesRoot(), 2)
+}
+
+func TestWriteSyncs(t *testing.T) {
+	b := newFaulty()
+	dir := fs.NewDir(b)
+	assert.NoError(t, dir.CreateDir("pkg"))
+	withFile(dir, "pkg/metadata.toml", "old")
+	b.syncs = nil
+	assert.NoError(t, dir.WriteToFile("pkg/metadata.toml", "new"))
+	assert.Len(t, b.syncs, 2)
+	assert.Equal(t, "pkg", b.syncs[1])
+	content, err := dir.CatFile("pkg/metadata.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", content)
+}
+
+func TestDurableAppend(t *testing.T) {
+	b := newFaulty()
+	dir := withFile(fs.NewDir(b), "log", "a")
+	b.syncs = nil
+	assert.NoError(t, dir.AppendToFile("log", "b"))
+	assert.Empty(t, b.syncs)
+
+	dir.SetDurable(true)
+	assert.NoError(t, dir.AppendToFile("log", "c"))
+	assert.Equal(t, []string{"log"}, b.syncs)
+	b.fail["sync"] = true
+	assert.ErrorIs(t, dir.AppendToFile("log", "d"), errCrash)
+	content, err := dir.CatFile("log")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd", content)
+}
+
+func TestWriteAtomicOnDisk(t *testing.T) {
+	dir := withFile(tempDir(t), "metadata.toml", "old")
+	dir.SetDurable(true)
+	assert.NoError(t, dir.Chmod("metadata.toml", 0600))
+	assert.NoError(t, dir.WriteToFile("metadata.toml", "new"))
+	assert.NoError(t, dir.AppendToFile("metadata.toml", "er"))
+	content, err := dir.CatFile("metadata.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, "newer", content)
+	info, err := dir.Stat("metadata.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, 0600, int(info.Mode().Perm()))
+	assert.Equal(t, []string{"metadata.toml"}, dir.ListFilesRoot())
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jwtAdvisory = `{
+  "id": "GO-2024-0001",
+  "summary": "Token signature bypass in jwt",
+  "affected": [{
+    "package": {"ecosystem": "GOPI", "name": "jwt"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "5.0.1"}]}]
+  }],
+  "database_specific": {"severity": "high"}
+}`
+
+const otherAdvisories = `[
+  {
+    "id": "GO-2024-0002",
+    "summary": "Template injection",
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N"}],
+    "affected": [{
+      "package": {"ecosystem": "Go", "name": "fasttemplate"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "1.0.0"}, {"last_affected": "1.2.0"}]}]
+    }]
+  },
+  {
+    "id": "GO-2024-0003",
+    "summary": "Old echo bug",
+    "affected": [{"package": {"ecosystem": "GOPI", "name": "echo"}, "versions": ["4.10.0"]}]
+  }
+]`
+
+func advisoryDB(t *testing.T) *commands.AdvisoryDB {
+	dir := tempDir(t)
+	dir.CreateDir("osv")
+	withFile(dir, "osv/jwt.json", jwtAdvisory)
+	withFile(dir, "osv/others.json", otherAdvisories)
+	withFile(dir, "osv/README.md", "not an advisory")
+	db, err := commands.LoadAdvisories(dir, "osv")
+	assert.NoError(t, err)
+	return db
+}
+
+func TestAdvisoryLookup(t *testing.T) {
+	db := advisoryDB(t)
+	findings := db.Lookup("jwt", "5.0.0")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "GO-2024-0001", findings[0].ID)
+	assert.Equal(t, "HIGH", findings[0].Severity)
+	assert.Equal(t, []string{"5.0.1"}, findings[0].Fixed)
+
+	assert.Empty(t, db.Lookup("jwt", "5.0.1"))
+	findings = db.Lookup("fasttemplate", "1.2.0")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "MEDIUM", findings[0].Severity)
+	assert.Equal(t, "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", findings[0].Score)
+	assert.Empty(t, db.Lookup("fasttemplate", "1.2.2"))
+	findings = db.Lookup("echo", "4.10.0")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "UNKNOWN", findings[0].Severity)
+	assert.Empty(t, findings[0].Score)
+	assert.Empty(t, db.Lookup("echo", "4.11.0"))
+	assert.Empty(t, db.Lookup("go-spew", ""))
+}
+
+func TestAdvisorySeverity(t *testing.T) {
+	for vector, severity := range map[string]string{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H":     "CRITICAL",
+		"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H":     "CRITICAL",
+		"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:N":     "HIGH",
+		"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N":     "MEDIUM",
+		"CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N/E:P": "LOW",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N":     "NONE",
+		"CVSS:3.1/AV:N": "UNKNOWN",
+		"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N": "UNKNOWN",
+	} {
+		dir := withFile(fs.MemDir(), "adv.json", `{
+  "id": "GO-2024-0004",
+  "severity": [{"type": "CVSS_V3", "score": "`+vector+`"}],
+  "affected": [{"package": {"ecosystem": "Go", "name": "jwt"}, "versions": ["5.0.0"]}]
+}`)
+		db, err := commands.LoadAdvisories(dir, "")
+		assert.NoError(t, err)
+		findings := db.Lookup("jwt", "5.0.0")
+		assert.Len(t, findings, 1)
+		assert.Equal(t, severity, findings[0].Severity, vector)
+		assert.Equal(t, vector, findings[0].Score)
+	}
+}
+
+func TestAdvisoryEcosystem(t *testing.T) {
+	dir := withFile(fs.MemDir(), "adv.json", `[
+  {
+    "id": "GHSA-npm-jwt",
+    "affected": [{"package": {"ecosystem": "npm", "name": "jwt"}, "versions": ["5.0.0"]}]
+  },
+  {
+    "id": "PYSEC-jwt",
+    "affected": [
+      {"package": {"ecosystem": "PyPI", "name": "jwt"}, "versions": ["5.0.0"]},
+      {"package": {"ecosystem": "Go", "name": "jwt"}, "versions": ["4.0.0"]}
+    ]
+  }
+]`)
+	db, err := commands.LoadAdvisories(dir, "")
+	assert.NoError(t, err)
+	assert.Empty(t, db.Lookup("jwt", "5.0.0"))
+	findings := db.Lookup("jwt", "4.0.0")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "PYSEC-jwt", findings[0].ID)
+}
+
+func TestLoadAdvisoriesFile(t *testing.T) {
+	dir := withFile(tempDir(t), "db.json", otherAdvisories)
+	db, err := commands.LoadAdvisories(dir, "db.json")
+	assert.NoError(t, err)
+	assert.Len(t, db.Lookup("echo", "4.10.0"), 1)
+
+	withFile(dir, "broken.json", "{")
+	_, err = commands.LoadAdvisories(dir, "broken.json")
+	assert.Error(t, err)
+}
+
+func TestAudit1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("echo"))
+
+	findings, err := pip.Audit()
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+
+	pip.SetAdvisories(advisoryDB(t))
+	findings, err = pip.Audit()
+	assert.NoError(t, err)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "jwt", findings[0].Package)
+	assert.Equal(t, "5.0.0", findings[0].Version)
+}
+
+func TestAuditGate(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	pip.SetAdvisories(advisoryDB(t))
+	assert.NoError(t, pip.Install("testify"))
+
+	pip.SetBlockVulnerable(true)
+	err := pip.Install("echo")
+	assert.ErrorIs(t, err, commands.ErrVulnerable)
+	assert.Contains(t, err.Error(), "GO-2024-0001")
+	assert.NotContains(t, pip.AllInstalledPackages(), "echo")
+	assert.NotContains(t, pip.AllInstalledPackages(), "jwt")
+}
+
+func TestCompareVersionsPreRelease(t *testing.T) {
+	ordered := []string{
+		"0.0.0-20230101120000-abcdef123456",
+		"0.0.0-20240101120000-123456abcdef",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"5.0.0",
+		"5.0.1-rc.1",
+		"v5.0.1+incompatible",
+	}
+	for i := range ordered {
+		assert.True(t, commands.ValidVersion(ordered[i]), ordered[i])
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			assert.Equal(t, want, commands.CompareVersions(ordered[i], ordered[j]), "%s %s", ordered[i], ordered[j])
+		}
+	}
+	assert.False(t, commands.ValidVersion("1.0.0-"))
+	assert.False(t, commands.ValidVersion("1.0.0-rc..1"))
+	assert.False(t, commands.ValidVersion("1.0.0-rc_1"))
+}
+
+func TestAdvisoryPreReleaseRanges(t *testing.T) {
+	dir := withFile(fs.MemDir(), "GO-2024-0002.json", `{
+  "id": "GO-2024-0002",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "jwt"},
+    "ranges": [
+      {"type": "SEMVER", "events": [{"introduced": "0.0.0-20230101120000-abcdef123456"}, {"fixed": "5.0.1-rc.1"}]},
+      {"type": "GIT", "repo": "https://example.com/jwt", "events": [{"introduced": "0"}, {"fixed": "a1b2c3d"}]}
+    ]
+  }]
+}`)
+	db, err := commands.LoadAdvisories(dir, "")
+	assert.NoError(t, err)
+	findings := db.Lookup("jwt", "5.0.0")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, []string{"5.0.1-rc.1"}, findings[0].Fixed)
+	assert.Len(t, db.Lookup("jwt", "5.0.1-beta"), 1)
+	assert.Len(t, db.Lookup("jwt", "0.0.0-20230601120000-abcdef123456"), 1)
+	assert.Empty(t, db.Lookup("jwt", "0.0.0-20221201120000-abcdef123456"))
+	assert.Empty(t, db.Lookup("jwt", "5.0.1-rc.1"))
+	assert.Empty(t, db.Lookup("jwt", "5.0.1"))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"pip/commands"
+	"pip/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemDir(t *testing.T) {
+	dir := fs.MemDir()
+	assert.NoError(t, dir.CreateDir("src"))
+	assert.NoError(t, dir.CreateFile("src/main.go"))
+	assert.NoError(t, dir.CreateFile("README.md"))
+	assert.Error(t, dir.CreateFile("docs/index.md"))
+	assert.ElementsMatch(t, []string{"README.md", "src/main.go"}, dir.ListFilesRoot())
+
+	assert.NoError(t, dir.WriteToFile("src/main.go", "package main"))
+	assert.NoError(t, dir.AppendToFile("src/main.go", "\nfunc main(){}\n"))
+	content, err := dir.CatFile("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package main\nfunc main(){}\n", content)
+	assert.Error(t, dir.AppendToFile("src/non_existing_file", "salam"))
+	_, err = dir.CatFile("src")
+	assert.Error(t, err)
+
+	cloned := dir.Clone()
+	assert.NoError(t, cloned.CreateFile("LICENSE"))
+	assert.NotContains(t, dir.ListFilesRoot(), "LICENSE")
+
+	assert.NoError(t, dir.Remove("src"))
+	assert.Equal(t, []string{"README.md"}, dir.ListFilesRoot())
+	assert.Contains(t, cloned.ListFilesRoot(), "src/main.go")
+}
+
+func TestBackendsFS(t *testing.T) {
+	for _, dir := range []*fs.Dir{fs.MemDir(), tempDir(t)} {
+		generateProjectIn(dir, "jwt")
+		assert.NoError(t, fstest.TestFS(dir.Backend(), "requirements.txt", "src/main.go"))
+	}
+}
+
+func TestMountAcrossBackends(t *testing.T) {
+	disk := tempDir(t)
+	assert.NoError(t, disk.Mount("pkg", generateProject("jwt")))
+	content, err := disk.CatFile("pkg/requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt\n", content)
+
+	mem := fs.MemDir()
+	assert.NoError(t, mem.Mount("copy", disk))
+	assert.ElementsMatch(t,
+		[]string{"copy/pkg/requirements.txt", "copy/pkg/src/main.go"},
+		mem.ListFilesRoot(),
+	)
+}
+
+func TestMemUnsupported(t *testing.T) {
+	dir := fs.MemDir()
+	_, err := dir.Run(context.Background(), "", "true")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	assert.ErrorIs(t, dir.Link("other", fs.MemDir()), errors.ErrUnsupported)
+	unlock, err := dir.Lock()
+	assert.NoError(t, err)
+	unlock()
+}
+
+func TestRunConfined(t *testing.T) {
+	dir := tempDir(t)
+	assert.NoError(t, dir.CreateDir("pkg"))
+	assert.NoError(t, dir.WriteBytes("pkg/in.txt", []byte("in\n")))
+	outside := tempDir(t)
+	ctx := context.Background()
+
+	out, err := dir.Run(ctx, "pkg", "sh", "-c", "cat in.txt && echo out > out.txt && echo $HOME")
+	assert.NoError(t, err)
+	assert.Equal(t, "in\n"+dir.Path()+"/pkg\n", out)
+	content, err := dir.CatFile("pkg/out.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "out\n", content)
+
+	// reading outside is fine, changing anything is not
+	withFile(outside, "shared.txt", "shared\n")
+	out, err = dir.Run(ctx, "pkg", "cat", outside.Path()+"/shared.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "shared\n", out)
+	for _, script := range []string{
+		"echo escaped > " + outside.Path() + "/escaped.txt",
+		"echo escaped > ../escaped.txt",
+		"mkdir " + outside.Path() + "/escaped",
+	} {
+		_, err := dir.Run(ctx, "pkg", "sh", "-c", script)
+		assert.Error(t, err, script)
+	}
+	assert.Equal(t, []string{"shared.txt"}, outside.ListFilesRoot())
+	assert.ElementsMatch(t, []string{"pkg/in.txt", "pkg/out.txt"}, dir.ListFilesRoot())
+
+	// the process running the commands is not confined itself
+	assert.NoError(t, outside.WriteBytes("after.txt", []byte("after\n")))
+}
+
+func TestPIPInMemory(t *testing.T) {
+	dir := fs.MemDir()
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("echo"))
+	assert.Contains(t, dir.ListFilesRoot(), "echo/gopi.toml")
+	assert.NoError(t, pip.Check())
+	assert.Empty(t, pip.Verify())
+
+	licenses, err := pip.Licenses()
+	assert.NoError(t, err)
+	assert.Contains(t, licenses, commands.LicenseInfo{Package: "echo", License: "MIT", Source: commands.ManifestFile})
+
+	assert.NoError(t, dir.WriteToFile("jwt/src/main.go", "tampered"))
+	assert.Len(t, pip.Verify(), 1)
+	assert.NoError(t, pip.Fix())
+	assert.Empty(t, pip.Verify())
+
+	assert.NoError(t, pip.Uninstall("echo"))
+	assert.Empty(t, dir.ListFilesRoot())
+}
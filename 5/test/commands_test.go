@@ -2,7 +2,6 @@ package main
 
 import (
 	"pip/commands"
-	"pip/fs"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -77,14 +76,14 @@ func TestAllDeps4(t *testing.T) {
 }
 
 func TestInstall1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{"echo"}, pip.AllUserInstalledPackages())
 }
 
 func TestInstall2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	assert.ElementsMatch(t,
@@ -102,7 +101,7 @@ func TestInstall2(t *testing.T) {
 }
 
 func TestInstall3(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	err = pip.Install("echo")
@@ -111,7 +110,7 @@ func TestInstall3(t *testing.T) {
 }
 
 func TestInstall4(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	err = pip.Install("jwt")
@@ -132,14 +131,14 @@ func TestInstall4(t *testing.T) {
 }
 
 func TestInstall5(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo", "jwt")
 	assert.NoError(t, err)
 	assert.ElementsMatch(t, []string{"echo", "jwt"}, pip.AllUserInstalledPackages())
 }
 
 func TestInstall6(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("numpy")
 	assert.Error(t, err)
 	assert.NotContains(t, pip.AllUserInstalledPackages(), "numpy")
@@ -147,7 +146,7 @@ func TestInstall6(t *testing.T) {
 }
 
 func TestInstall7(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("jwt", "numpy", "go-spew")
 	assert.Error(t, err)
 
@@ -162,7 +161,7 @@ func TestInstall7(t *testing.T) {
 	assert.NotContains(t, pip.AllInstalledPackages(), "go-spew")
 }
 func TestInstall8(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("jwt", "prj-with-indirect-invalid-dep", "go-spew")
 	assert.Error(t, err)
 
@@ -178,8 +177,8 @@ func TestInstall8(t *testing.T) {
 }
 
 func TestInstallR1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
-	d := fs.MkDir()
+	pip := commands.NewPIP(tempDir(t), gopi)
+	d := tempDir(t)
 	err := d.CreateFile("requirements.txt")
 	assert.NoError(t, err)
 	err = d.AppendToFile("requirements.txt", "echo\n")
@@ -195,8 +194,8 @@ func TestInstallR1(t *testing.T) {
 }
 
 func TestInstallR2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
-	d := fs.MkDir()
+	pip := commands.NewPIP(tempDir(t), gopi)
+	d := tempDir(t)
 	err := d.CreateFile("requirements.txt")
 	assert.NoError(t, err)
 	err = d.AppendToFile("requirements.txt", "echo\n")
@@ -213,7 +212,7 @@ func TestInstallR2(t *testing.T) {
 }
 
 func TestUninstall1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("jwt")
 	assert.NoError(t, err)
@@ -225,7 +224,7 @@ func TestUninstall1(t *testing.T) {
 }
 
 func TestUninstall2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -236,7 +235,7 @@ func TestUninstall2(t *testing.T) {
 	assert.Contains(t, pip.AllInstalledPackages(), "jwt")
 }
 func TestUninstall3(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo", "jwt")
 	assert.NoError(t, err)
@@ -248,7 +247,7 @@ func TestUninstall3(t *testing.T) {
 }
 
 func TestUninstall4(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -262,7 +261,7 @@ func TestUninstall4(t *testing.T) {
 }
 
 func TestUninstall5(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -279,7 +278,7 @@ func TestUninstall5(t *testing.T) {
 }
 
 func TestUninstall6(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -290,7 +289,7 @@ func TestUninstall6(t *testing.T) {
 }
 
 func TestUninstall7(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -302,7 +301,7 @@ func TestUninstall7(t *testing.T) {
 	assert.Contains(t, pip.AllInstalledPackages(), "jwt")
 }
 func TestUninstall8(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo", "jwt")
 	assert.NoError(t, err)
@@ -316,7 +315,7 @@ func TestUninstall8(t *testing.T) {
 }
 
 func TestForceUninstall1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -326,7 +325,7 @@ func TestForceUninstall1(t *testing.T) {
 }
 
 func TestForceUninstall2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -336,7 +335,7 @@ func TestForceUninstall2(t *testing.T) {
 }
 
 func TestCheck1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -350,7 +349,7 @@ func TestCheck1(t *testing.T) {
 }
 
 func TestCheck2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -373,7 +372,7 @@ func TestCheck2(t *testing.T) {
 }
 
 func TestFix1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -381,13 +380,13 @@ func TestFix1(t *testing.T) {
 	err = pip.UninstallForce("echo")
 	assert.NoError(t, err)
 
-	pip.Fix()
+	assert.NoError(t, pip.Fix())
 
 	assert.Empty(t, pip.AllInstalledPackages())
 }
 
 func TestFix2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 
 	err := pip.Install("echo")
 	assert.NoError(t, err)
@@ -401,7 +400,7 @@ func TestFix2(t *testing.T) {
 	err = pip.Check()
 	assert.Error(t, err)
 
-	pip.Fix()
+	assert.NoError(t, pip.Fix())
 
 	err = pip.Check()
 	assert.NoError(t, err)
@@ -416,7 +415,7 @@ func TestFix2(t *testing.T) {
 }
 
 func TestImportCheck1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	src := `package main
 import (
 	"echo"
@@ -444,7 +443,7 @@ func main(){
 }
 
 func TestImportCheck2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	src := `package main
 
 import ( 	"fmt"
@@ -459,7 +458,7 @@ func main() {
 }
 
 func TestImportCheck3(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	src := `package main
 
 import ( 	"fmt"
@@ -501,7 +500,7 @@ func main() {
 }
 
 func TestImportCheck4(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	src := `package main
 
 import 			"crypto"
@@ -523,7 +522,7 @@ func main() {
 }
 
 func TestSearch1(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	result := pip.LocalSearch("ech")
@@ -531,7 +530,7 @@ func TestSearch1(t *testing.T) {
 }
 
 func TestSearch2(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	result := pip.LocalSearch("cho")
@@ -539,7 +538,7 @@ func TestSearch2(t *testing.T) {
 }
 
 func TestSearch3(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	result := pip.LocalSearch("e")
@@ -550,7 +549,7 @@ func TestSearch3(t *testing.T) {
 }
 
 func TestSearch4(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	result := pip.LocalSearch("te")
@@ -561,7 +560,7 @@ func TestSearch4(t *testing.T) {
 }
 
 func TestSearch5(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 	result := pip.LocalSearch("tet")
@@ -572,7 +571,7 @@ func TestSearch5(t *testing.T) {
 }
 
 func TestSearch6(t *testing.T) {
-	pip := commands.NewPIP(fs.MkDir(), gopi)
+	pip := commands.NewPIP(tempDir(t), gopi)
 	err := pip.Install("echo")
 	assert.NoError(t, err)
 
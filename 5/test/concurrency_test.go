@@ -0,0 +1,73 @@
+package main
+
+import (
+	"pip/commands"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentInstall(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	pkgs := []string{"echo", "jwt", "testify", "fasttemplate", "go-spew"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		for _, pkg := range pkgs {
+			wg.Add(2)
+			go func(pkg string) {
+				defer wg.Done()
+				assert.NoError(t, pip.Install(pkg))
+			}(pkg)
+			go func() {
+				defer wg.Done()
+				pip.AllInstalledPackages()
+				pip.LocalSearch("e")
+				pip.Check()
+				pip.Licenses()
+			}()
+		}
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, pkgs, pip.AllUserInstalledPackages())
+	assert.NoError(t, pip.Check())
+
+	for _, pkg := range pkgs {
+		wg.Add(1)
+		go func(pkg string) {
+			defer wg.Done()
+			pip.UninstallForce(pkg)
+		}(pkg)
+	}
+	wg.Wait()
+	assert.Empty(t, pip.AllUserInstalledPackages())
+}
+
+func TestDirLock(t *testing.T) {
+	dir := tempDir(t)
+	unlock, err := dir.Lock()
+	assert.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock2, err := dir.Lock()
+		assert.NoError(t, err)
+		close(acquired)
+		unlock2()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock was not released")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"pip/commands"
+	"pip/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type slowGOPI struct {
+	delay time.Duration
+}
+
+func (g slowGOPI) Get(pkgName string) (*fs.Dir, error) {
+	time.Sleep(g.delay)
+	return gopi.Get(pkgName)
+}
+
+type contextOnlyGOPI struct {
+	calls int
+}
+
+func (g *contextOnlyGOPI) GetContext(ctx context.Context, pkgName string) (*fs.Dir, error) {
+	g.calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return gopi.Get(pkgName)
+}
+
+func TestInstallContextTimeout(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), slowGOPI{delay: 5 * time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := pip.InstallContext(ctx, "jwt")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestInstallContextLocked(t *testing.T) {
+	dir := tempDir(t)
+	// another process holding the install dir
+	other, err := fs.Open(dir.Path())
+	assert.NoError(t, err)
+	unlock, err := other.Lock()
+	assert.NoError(t, err)
+
+	pip := commands.NewPIP(dir, gopi)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, pip.InstallContext(ctx, "jwt"), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	_, err = dir.LockContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	unlock()
+	assert.NoError(t, pip.InstallContext(context.Background(), "jwt"))
+
+	// another goroutine of this process holding the PIP
+	pip = commands.NewPIP(tempDir(t), slowGOPI{delay: 300 * time.Millisecond})
+	done := make(chan error)
+	go func() { done <- pip.Install("jwt") }()
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	assert.ErrorIs(t, pip.UninstallContext(ctx, "jwt"), context.DeadlineExceeded)
+	_, err = pip.AllDepsContext(ctx, "jwt")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"jwt"}, pip.AllInstalledPackages())
+}
+
+func TestContextGOPI(t *testing.T) {
+	registry := &contextOnlyGOPI{}
+	pip := commands.NewPIPContext(tempDir(t), registry)
+	assert.NoError(t, pip.InstallContext(context.Background(), "echo"))
+	assert.Contains(t, pip.AllInstalledPackages(), "go-spew")
+	assert.NotZero(t, registry.calls)
+
+	deps, err := pip.AllDepsContext(context.Background(), "testify")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"go-spew", "go-difflib"}, deps)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pip.AllDepsContext(ctx, "echo")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, pip.UninstallContext(ctx, "echo"), context.Canceled)
+	assert.Contains(t, pip.AllInstalledPackages(), "echo")
+
+	assert.NoError(t, pip.UninstallContext(context.Background(), "echo"))
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestFixAndInstallRContext(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	req := withFile(tempDir(t), "requirements.txt", "echo\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, pip.InstallRContext(ctx, req, "requirements.txt"), context.Canceled)
+	assert.NoError(t, pip.InstallRContext(context.Background(), req, "requirements.txt"))
+
+	assert.NoError(t, pip.UninstallForce("jwt"))
+	assert.ErrorIs(t, pip.FixContext(ctx), context.Canceled)
+	assert.NoError(t, pip.FixContext(context.Background()))
+	assert.NoError(t, pip.Check())
+}
+
+func TestMountContext(t *testing.T) {
+	dir := tempDir(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := dir.MountContext(ctx, "pkg", generateProject("jwt"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, dir.ListFilesRoot())
+
+	_, err = generateProject().CloneContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
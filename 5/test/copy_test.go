@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"pip/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sameFile(t *testing.T, a, b *fs.Dir, file string) bool {
+	t.Helper()
+	infoA, err := os.Stat(filepath.Join(a.Path(), file))
+	assert.NoError(t, err)
+	infoB, err := os.Stat(filepath.Join(b.Path(), file))
+	assert.NoError(t, err)
+	return os.SameFile(infoA, infoB)
+}
+
+func TestCloneHardlink(t *testing.T) {
+	src := generateProjectIn(tempDir(t), "jwt")
+	src.SetCopyStrategy(fs.CopyHardlink)
+	clone := src.Clone()
+	defer clone.Close()
+	assert.True(t, sameFile(t, src, clone, "requirements.txt"))
+	assert.True(t, sameFile(t, src, clone, "src/main.go"))
+
+	// writes give the clone its own copy
+	assert.NoError(t, clone.WriteToFile("requirements.txt", "testify\n"))
+	assert.NoError(t, clone.AppendToFile("src/main.go", "\npackage main"))
+	assert.False(t, sameFile(t, src, clone, "requirements.txt"))
+	assert.False(t, sameFile(t, src, clone, "src/main.go"))
+
+	content, err := src.CatFile("requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt\n", content)
+	content, err = src.CatFile("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "// TODO: implement", content)
+	content, err = clone.CatFile("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "// TODO: implement\npackage main", content)
+
+	// clones keep the strategy
+	again := clone.Clone()
+	defer again.Close()
+	assert.True(t, sameFile(t, clone, again, "src/main.go"))
+}
+
+func TestMountHardlink(t *testing.T) {
+	src := generateProjectIn(tempDir(t), "jwt")
+	dst := tempDir(t)
+	dst.SetCopyStrategy(fs.CopyHardlink)
+	assert.NoError(t, dst.Mount("jwt", src))
+	content, err := dst.CatFile("jwt/requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt\n", content)
+
+	assert.NoError(t, dst.CreateFile("jwt/requirements.txt"))
+	content, err = src.CatFile("requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt\n", content)
+}
+
+func TestCloneReflink(t *testing.T) {
+	// without reflink support in the temp filesystem this falls back to copy
+	src := generateProjectIn(tempDir(t), "jwt")
+	src.SetCopyStrategy(fs.CopyReflink)
+	clone := src.Clone()
+	defer clone.Close()
+	assert.ElementsMatch(t, src.ListFilesRoot(), clone.ListFilesRoot())
+	assert.False(t, sameFile(t, src, clone, "requirements.txt"))
+
+	assert.NoError(t, clone.WriteToFile("requirements.txt", "testify\n"))
+	content, err := src.CatFile("requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt\n", content)
+}
+
+func benchTree(b *testing.B, dir *fs.Dir) *fs.Dir {
+	b.Helper()
+	content := strings.Repeat("x", 32<<10)
+	for i := 0; i < 10; i++ {
+		sub := fmt.Sprintf("pkg%d", i)
+		if err := dir.CreateDir(sub); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < 20; j++ {
+			file := fmt.Sprintf("%s/file%d.go", sub, j)
+			dir.CreateFile(file)
+			if err := dir.WriteToFile(file, content); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return dir
+}
+
+// BenchmarkClone is what LocalGOPI.Get costs for a package of 200 files.
+func BenchmarkClone(b *testing.B) {
+	strategies := []struct {
+		name     string
+		strategy fs.CopyStrategy
+	}{
+		{"deep", fs.CopyDeep},
+		{"hardlink", fs.CopyHardlink},
+		{"reflink", fs.CopyReflink},
+	}
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			src := benchTree(b, tempDir(b))
+			src.SetCopyStrategy(s.strategy)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				clone := src.Clone()
+				b.StopTimer()
+				clone.Close()
+				b.StartTimer()
+			}
+		})
+	}
+	b.Run("memory", func(b *testing.B) {
+		src := benchTree(b, fs.MemDir())
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			src.Clone()
+		}
+	})
+}
+
+func TestCloneHardlinkCowSibling(t *testing.T) {
+	src := withFile(withFile(tempDir(t), "b", "x"), "b.cow", "keep")
+	src.SetCopyStrategy(fs.CopyHardlink)
+	clone := src.Clone()
+	defer clone.Close()
+	assert.NoError(t, clone.AppendToFile("b", "y"))
+
+	content, err := clone.CatFile("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "xy", content)
+	assert.ElementsMatch(t, []string{"b", "b.cow"}, clone.ListFilesRoot())
+	for _, dir := range []*fs.Dir{src, clone} {
+		content, err = dir.CatFile("b.cow")
+		assert.NoError(t, err)
+		assert.Equal(t, "keep", content)
+	}
+	content, err = src.CatFile("b")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", content)
+}
+
+func TestChmodHardlink(t *testing.T) {
+	src := withFile(tempDir(t), "a.sh", "#!/bin/sh\n")
+	src.SetCopyStrategy(fs.CopyHardlink)
+	clone := src.Clone()
+	defer clone.Close()
+	assert.NoError(t, clone.Chmod("a.sh", 0755))
+	assert.False(t, sameFile(t, src, clone, "a.sh"))
+
+	info, err := src.Stat("a.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, 0644, int(info.Mode().Perm()))
+	content, err := clone.CatFile("a.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", content)
+}
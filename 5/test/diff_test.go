@@ -0,0 +1,73 @@
+package main
+
+import (
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := generateProject("jwt", "testify")
+	after := before.Clone()
+	assert.NoError(t, after.WriteToFile("requirements.txt", "jwt\ntestify\necho\n"))
+	assert.NoError(t, after.Remove("src/main.go"))
+	assert.NoError(t, after.CreateFile("CHANGELOG.md"))
+	assert.NoError(t, after.WriteToFile("CHANGELOG.md", "v2\n"))
+
+	diff, err := fs.Diff(before, after)
+	assert.NoError(t, err)
+	assert.False(t, diff.Empty())
+	assert.Equal(t, []string{"CHANGELOG.md"}, diff.Added)
+	assert.Equal(t, []string{"src/main.go"}, diff.Removed)
+	assert.Equal(t, []string{"requirements.txt"}, diff.Modified)
+
+	unified, err := diff.Unified()
+	assert.NoError(t, err)
+	assert.Equal(t, `--- /dev/null
++++ b/CHANGELOG.md
+@@ -0,0 +1 @@
++v2
+--- a/requirements.txt
++++ b/requirements.txt
+@@ -1,2 +1,3 @@
+ jwt
+ testify
++echo
+--- a/src/main.go
++++ /dev/null
+@@ -1 +0,0 @@
+-// TODO: implement
+`, unified)
+}
+
+func TestDiffSame(t *testing.T) {
+	disk := tempDir(t)
+	project := generateProject("jwt")
+	assert.NoError(t, disk.Mount("copy", project))
+
+	diff, err := fs.Diff(project, project.Clone())
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+	unified, err := diff.Unified()
+	assert.NoError(t, err)
+	assert.Empty(t, unified)
+
+	// the same content on another backend is no change either
+	opened, err := fs.Open(disk.Path() + "/copy")
+	assert.NoError(t, err)
+	diff, err = fs.Diff(project, opened)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestDiffBinary(t *testing.T) {
+	a := withFile(fs.MemDir(), "logo.png", "\x89PNG\x00\x01")
+	b := withFile(fs.MemDir(), "logo.png", "\x89PNG\x00\x02")
+	diff, err := fs.Diff(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"logo.png"}, diff.Modified)
+	unified, err := diff.Unified()
+	assert.NoError(t, err)
+	assert.Equal(t, "Binary files a/logo.png and b/logo.png differ\n", unified)
+}
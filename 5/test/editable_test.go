@@ -0,0 +1,124 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// editable installs link the project, so it has to be on disk
+func localProject(t testing.TB) *fs.Dir {
+	return withManifest(generateProjectIn(tempDir(t), "testify"), `
+name = "my-app"
+version = "0.0.1"
+license = "MIT"
+`)
+}
+
+func TestInstallEditable1(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	project := localProject(t)
+	assert.NoError(t, pip.InstallEditable(project))
+
+	assert.True(t, pip.IsEditable("my-app"))
+	assert.Contains(t, pip.AllUserInstalledPackages(), "my-app")
+	assert.NotContains(t, pip.AllUserInstalledPackages(), "testify")
+	assert.ElementsMatch(t,
+		[]string{"my-app", "testify", "go-spew", "go-difflib"},
+		pip.AllInstalledPackages(),
+	)
+	assert.NoError(t, pip.Check())
+
+	// edits in the project are visible through the install dir at once
+	assert.NoError(t, project.WriteToFile("src/main.go", "package app"))
+	content, err := dir.CatFile("my-app/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package app", content)
+
+	meta, err := pip.Show("my-app")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.1", meta.Version)
+	assert.Empty(t, pip.Verify())
+}
+
+func TestInstallEditableDeps(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	project := localProject(t)
+	assert.NoError(t, pip.InstallEditable(project))
+
+	// dependencies follow the local requirements.txt
+	assert.NoError(t, project.AppendToFile("requirements.txt", "jwt\n"))
+	assert.Error(t, pip.Check())
+	assert.NoError(t, pip.InstallEditable(project))
+	assert.Contains(t, pip.AllInstalledPackages(), "jwt")
+	assert.NoError(t, pip.Check())
+
+	assert.NoError(t, project.WriteToFile("requirements.txt", "jwt\n"))
+	danglings, err := pip.FindDanglings()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"testify", "go-spew", "go-difflib"}, danglings)
+}
+
+func TestUninstallEditable(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	project := localProject(t)
+	assert.NoError(t, pip.InstallEditable(project))
+	assert.NoError(t, pip.Uninstall("my-app"))
+
+	assert.Empty(t, pip.AllInstalledPackages())
+	assert.False(t, pip.IsEditable("my-app"))
+	assert.Contains(t, project.ListFilesRoot(), "src/main.go")
+	assert.Empty(t, dir.ListFilesRoot())
+}
+
+func TestInstallEditableErrors(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.ErrorIs(t, pip.InstallEditable(generateProject()), commands.ErrNoManifest)
+
+	project := withManifest(generateProjectIn(tempDir(t), "numpy"), `name = "my-app"`)
+	assert.ErrorIs(t, pip.InstallEditable(project), commands.ErrPackageNotFound)
+	assert.Empty(t, pip.AllInstalledPackages())
+	assert.False(t, pip.IsEditable("my-app"))
+
+	assert.NoError(t, pip.Install("jwt"))
+	assert.Error(t, pip.InstallEditable(withManifest(generateProject(), `name = "jwt"`)))
+}
+
+func TestCheckEditableGone(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	project := localProject(t)
+	assert.NoError(t, pip.InstallEditable(project))
+	assert.NoError(t, project.Remove(""))
+	assert.ErrorIs(t, pip.Check(), commands.ErrNotInstalled)
+	assert.NoError(t, pip.UninstallForce("my-app"))
+}
+
+func TestInstallEditableMoved(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.InstallEditable(localProject(t)))
+
+	// the same package from another directory replaces the link
+	moved := localProject(t)
+	assert.NoError(t, moved.WriteToFile("src/main.go", "package moved"))
+	assert.NoError(t, pip.InstallEditable(moved))
+	content, err := dir.CatFile("my-app/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package moved", content)
+
+	// a failed re-link keeps the package where it was
+	broken := withManifest(generateProjectIn(tempDir(t), "numpy"), `name = "my-app"`)
+	assert.ErrorIs(t, pip.InstallEditable(broken), commands.ErrPackageNotFound)
+	assert.True(t, pip.IsEditable("my-app"))
+	content, err = dir.CatFile("my-app/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package moved", content)
+	assert.NoError(t, pip.Check())
+	meta, err := pip.Show("my-app")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.0.1", meta.Version)
+}
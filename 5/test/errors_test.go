@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrPackageNotFound(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	_, err := pip.AllDeps("prj-with-indirect-invalid-dep")
+	assert.ErrorIs(t, err, commands.ErrPackageNotFound)
+
+	err = pip.Install("numpy")
+	assert.ErrorIs(t, err, commands.ErrPackageNotFound)
+}
+
+func TestErrNotInstalled(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("echo"))
+
+	assert.ErrorIs(t, pip.Uninstall("jwt"), commands.ErrNotInstalled)
+	assert.ErrorIs(t, pip.UninstallForce("numpy"), commands.ErrNotInstalled)
+
+	assert.NoError(t, pip.UninstallForce("jwt"))
+	assert.ErrorIs(t, pip.Check(), commands.ErrNotInstalled)
+}
+
+func TestErrRequiredBy(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("echo", "jwt"))
+
+	err := pip.Uninstall("jwt")
+	var requiredBy *commands.ErrRequiredBy
+	assert.True(t, errors.As(err, &requiredBy))
+	assert.Equal(t, "jwt", requiredBy.Pkg)
+	assert.Equal(t, []string{"echo"}, requiredBy.Dependents)
+}
+
+func TestErrInvalidRequirements(t *testing.T) {
+	registry := &LocalGOPI{
+		data: map[string]*fs.Dir{
+			"bad-line": generateProject("jwt", "# comment", "jwt>>1"),
+			"no-reqs":  tempDir(t),
+			"jwt":      generateProject(),
+		},
+	}
+	pip := commands.NewPIP(tempDir(t), registry)
+
+	_, err := pip.DirectDeps("bad-line")
+	var invalid *commands.ErrInvalidRequirements
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, "bad-line", invalid.Pkg)
+	assert.Equal(t, 3, invalid.Line)
+
+	_, err = pip.DirectDeps("no-reqs")
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, "no-reqs", invalid.Pkg)
+	assert.Equal(t, 0, invalid.Line)
+}
+
+func TestFixReturnsError(t *testing.T) {
+	registry := &LocalGOPI{
+		data: map[string]*fs.Dir{
+			"app": generateProject("lib"),
+			"lib": generateProject(),
+		},
+	}
+	pip := commands.NewPIP(tempDir(t), registry)
+	assert.NoError(t, pip.Install("app"))
+	assert.NoError(t, pip.UninstallForce("lib"))
+	delete(registry.data, "lib")
+
+	assert.ErrorIs(t, pip.Fix(), commands.ErrPackageNotFound)
+	_, err := pip.FindDanglings()
+	assert.ErrorIs(t, err, commands.ErrPackageNotFound)
+	_, err = pip.AllNeededDepsForCurPkgs()
+	assert.ErrorIs(t, err, commands.ErrPackageNotFound)
+}
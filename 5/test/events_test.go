@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"pip/commands"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []commands.Event
+}
+
+func (r *eventRecorder) observe(e commands.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) packages(match func(commands.Event) (string, bool)) []string {
+	result := make([]string, 0)
+	for _, e := range r.events {
+		if pkg, ok := match(e); ok {
+			result = append(result, pkg)
+		}
+	}
+	return result
+}
+
+func TestInstallEvents(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	rec := &eventRecorder{}
+	pip.AddObserver(rec.observe)
+
+	assert.NoError(t, pip.Install("testify"))
+	assert.Equal(t, commands.ResolveStarted{Packages: []string{"testify"}}, rec.events[0])
+	fetched := rec.packages(func(e commands.Event) (string, bool) {
+		f, ok := e.(commands.PackageFetched)
+		if ok {
+			assert.Positive(t, f.Bytes)
+		}
+		return f.Package, ok
+	})
+	assert.ElementsMatch(t, []string{"testify", "go-spew", "go-difflib"}, fetched)
+	mounted := rec.packages(func(e commands.Event) (string, bool) {
+		m, ok := e.(commands.PackageMounted)
+		return m.Package, ok
+	})
+	assert.ElementsMatch(t, fetched, mounted)
+
+	rec.events = nil
+	assert.NoError(t, pip.Install("echo"))
+	skipped := rec.packages(func(e commands.Event) (string, bool) {
+		s, ok := e.(commands.PackageSkipped)
+		return s.Package, ok
+	})
+	assert.ElementsMatch(t, []string{"testify", "go-spew", "go-difflib"}, skipped)
+}
+
+func TestUninstallEvents(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("testify"))
+	rec := &eventRecorder{}
+	pip.AddObserver(rec.observe)
+
+	assert.NoError(t, pip.Uninstall("testify"))
+	removed := rec.packages(func(e commands.Event) (string, bool) {
+		r, ok := e.(commands.PackageRemoved)
+		return r.Package, ok
+	})
+	assert.ElementsMatch(t, []string{"testify", "go-spew", "go-difflib"}, removed)
+	cleaned := rec.packages(func(e commands.Event) (string, bool) {
+		c, ok := e.(commands.DanglingCleaned)
+		return c.Package, ok
+	})
+	assert.ElementsMatch(t, []string{"go-spew", "go-difflib"}, cleaned)
+}
+
+func TestSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	pip := commands.NewPIP(tempDir(t), gopi)
+	pip.AddObserver(commands.SlogObserver(logger))
+
+	assert.NoError(t, pip.Install("jwt"))
+	assert.Contains(t, buf.String(), `msg="package fetched" package=jwt bytes=`)
+	assert.Contains(t, buf.String(), `msg="package mounted" package=jwt`)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAPI(t *testing.T) {
+	for _, dir := range []*fs.Dir{fs.MemDir(), tempDir(t)} {
+		generateProjectIn(dir, "jwt")
+
+		info, err := dir.Stat("requirements.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len("jwt\n")), info.Size())
+		assert.Equal(t, 0644, int(info.Mode().Perm()))
+		info, err = dir.Stat("src")
+		assert.NoError(t, err)
+		assert.True(t, info.IsDir())
+		_, err = dir.Stat("missing")
+		assert.Error(t, err)
+
+		assert.NoError(t, dir.Rename("src/main.go", "app.go"))
+		assert.ElementsMatch(t, []string{"requirements.txt", "src/app.go"}, dir.ListFilesRoot())
+		assert.Error(t, dir.Rename("src/app.go", "../app.go"))
+
+		assert.NoError(t, dir.Move("src", "cmd/pip"))
+		assert.ElementsMatch(t, []string{"requirements.txt", "cmd/pip/app.go"}, dir.ListFilesRoot())
+		assert.Error(t, dir.Move("requirements.txt", "cmd/pip/app.go"))
+		assert.Error(t, dir.Move("requirements.txt", "../requirements.txt"))
+
+		dirs, err := dir.ListDirsIn("")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"cmd", "cmd/pip"}, dirs)
+
+		assert.NoError(t, dir.Chmod("cmd/pip/app.go", 0755))
+		info, err = dir.Stat("cmd/pip/app.go")
+		assert.NoError(t, err)
+		assert.Equal(t, 0755, int(info.Mode().Perm()))
+		// rewriting a file keeps its mode
+		assert.NoError(t, dir.WriteToFile("cmd/pip/app.go", "package main\n"))
+		info, err = dir.Stat("cmd/pip/app.go")
+		assert.NoError(t, err)
+		assert.Equal(t, 0755, int(info.Mode().Perm()))
+	}
+}
+
+func TestFileBytes(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0, 0xff, '\n', 0}
+	for _, dir := range []*fs.Dir{fs.MemDir(), tempDir(t)} {
+		assert.NoError(t, dir.WriteBytes("logo.png", binary))
+		content, err := dir.ReadBytes("logo.png")
+		assert.NoError(t, err)
+		assert.Equal(t, binary, content)
+
+		assert.NoError(t, dir.WriteBytes("logo.png", binary[:3]))
+		content, err = dir.ReadBytes("logo.png")
+		assert.NoError(t, err)
+		assert.Equal(t, binary[:3], content)
+		assert.Error(t, dir.WriteBytes("assets/logo.png", binary))
+	}
+}
+
+func TestFileStreams(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 64<<10)
+	for _, dir := range []*fs.Dir{fs.MemDir(), tempDir(t)} {
+		w, err := dir.OpenWriter("data.bin")
+		assert.NoError(t, err)
+		_, err = io.Copy(w, bytes.NewReader(large))
+		assert.NoError(t, err)
+		assert.NoError(t, w.Close())
+
+		r, err := dir.Open("data.bin")
+		assert.NoError(t, err)
+		content, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Close())
+		assert.Equal(t, large, content)
+
+		assert.NoError(t, dir.CreateDir("sub"))
+		_, err = dir.Open("sub")
+		assert.Error(t, err)
+		_, err = dir.Open("../data.bin")
+		assert.Error(t, err)
+	}
+}
+
+func TestPackKeepsExecutable(t *testing.T) {
+	project := generateProject("jwt")
+	assert.NoError(t, project.WriteBytes("run.sh", []byte("#!/bin/sh\n")))
+	assert.NoError(t, project.Chmod("run.sh", 0755))
+	data, err := commands.Pack(project)
+	assert.NoError(t, err)
+
+	unpacked := fs.MemDir()
+	assert.NoError(t, commands.UnpackTo(unpacked, data))
+	info, err := unpacked.Stat("run.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, 0755, int(info.Mode().Perm()))
+	info, err = unpacked.Stat("requirements.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, 0644, int(info.Mode().Perm()))
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"pip/commands"
+	"pip/fs"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,6 +73,7 @@ func TestWriteNotFound(t *testing.T) {
 
 func TestClone(t *testing.T) {
 	clonedWD := dir.Clone()
+	defer clonedWD.Close()
 	clonedWD.AppendToFile("README.md", "junk content")
 	clonedWD.CreateFile("LICENSE")
 
@@ -78,3 +83,59 @@ func TestClone(t *testing.T) {
 	assert.NotContains(t, dir.ListFilesRoot(), "LICENSE")
 	assert.Contains(t, clonedWD.ListFilesRoot(), "LICENSE")
 }
+
+func TestOpen(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "README.md"), []byte("hi"), 0644))
+	opened, err := fs.Open(root)
+	assert.NoError(t, err)
+	assert.Equal(t, root, opened.Path())
+	assert.Equal(t, []string{"README.md"}, opened.ListFilesRoot())
+
+	// only temporary dirs are removed on Close
+	assert.NoError(t, opened.Close())
+	assert.DirExists(t, root)
+
+	_, err = fs.Open(filepath.Join(root, "missing"))
+	assert.Error(t, err)
+	_, err = fs.Open(filepath.Join(root, "README.md"))
+	assert.Error(t, err)
+}
+
+func TestCreate(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "site-packages", "lib")
+	created, err := fs.Create(root)
+	assert.NoError(t, err)
+	assert.NoError(t, created.CreateFile("x.go"))
+	assert.FileExists(t, filepath.Join(root, "x.go"))
+
+	// creating an existing dir opens it
+	again, err := fs.Create(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"x.go"}, again.ListFilesRoot())
+
+	assert.NoError(t, again.RemoveAll())
+	assert.NoDirExists(t, root)
+}
+
+func TestCloseTemp(t *testing.T) {
+	temp := fs.MkDir()
+	assert.DirExists(t, temp.Path())
+	clone := temp.Clone()
+	assert.NotEqual(t, temp.Path(), clone.Path())
+	assert.NoError(t, temp.Close())
+	assert.NoError(t, clone.Close())
+	assert.NoDirExists(t, temp.Path())
+	assert.NoDirExists(t, clone.Path())
+
+	assert.Empty(t, fs.MemDir().Path())
+}
+
+func TestPIPOnCreatedDir(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "env")
+	installDir, err := fs.Create(root)
+	assert.NoError(t, err)
+	pip := commands.NewPIP(installDir, gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.FileExists(t, filepath.Join(root, "jwt", commands.ManifestFile))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func groupedProject() *fs.Dir {
+	return withManifest(generateProject("jwt"), `
+name = "my-service"
+
+[groups]
+dev = ["testify"]
+test = ["testify>=1.8", "fasttemplate"]
+`)
+}
+
+func TestInstallGroupsAll(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.InstallGroups(groupedProject(), commands.GroupOptions{}))
+	assert.ElementsMatch(t,
+		[]string{"jwt", "testify", "go-spew", "go-difflib", "fasttemplate", "bytebufferpool"},
+		pip.AllInstalledPackages(),
+	)
+	assert.Equal(t, map[string][]string{
+		"main": {"jwt"},
+		"dev":  {"testify"},
+		"test": {"testify", "fasttemplate"},
+	}, pip.Groups())
+}
+
+func TestInstallGroupsWithout(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.InstallGroups(groupedProject(), commands.GroupOptions{Without: []string{"dev", "test"}})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllInstalledPackages())
+
+	err = pip.InstallGroups(groupedProject(), commands.GroupOptions{Only: []string{"dev"}})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"jwt", "testify", "go-spew", "go-difflib"}, pip.AllInstalledPackages())
+
+	err = pip.InstallGroups(groupedProject(), commands.GroupOptions{Only: []string{"docs"}})
+	assert.ErrorIs(t, err, commands.ErrUnknownGroup)
+}
+
+func TestFreezeGroups(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.InstallGroups(groupedProject(), commands.GroupOptions{}))
+
+	lines, err := pip.Freeze(commands.MainGroup)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jwt==5.0.0"}, lines)
+
+	lines, err = pip.Freeze("dev")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go-difflib", "go-spew", "testify==1.8.2"}, lines)
+
+	lines, err = pip.Freeze()
+	assert.NoError(t, err)
+	assert.Len(t, lines, 6)
+
+	_, err = pip.Freeze("docs")
+	assert.ErrorIs(t, err, commands.ErrUnknownGroup)
+}
+
+func TestFindDanglingsGroups(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.InstallGroups(groupedProject(), commands.GroupOptions{}))
+
+	danglings, err := pip.FindDanglings()
+	assert.NoError(t, err)
+	assert.Empty(t, danglings)
+
+	// what a production environment would not need
+	danglings, err = pip.FindDanglings(commands.MainGroup)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t,
+		[]string{"testify", "go-spew", "go-difflib", "fasttemplate", "bytebufferpool"},
+		danglings,
+	)
+}
+
+func TestUninstallGroup(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.InstallGroups(groupedProject(), commands.GroupOptions{}))
+
+	// testify is still listed by the test group
+	assert.NoError(t, pip.UninstallGroup("dev"))
+	assert.Contains(t, pip.AllInstalledPackages(), "testify")
+
+	assert.NoError(t, pip.UninstallGroup("test"))
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllInstalledPackages())
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllUserInstalledPackages())
+	assert.NoError(t, pip.Check())
+
+	assert.ErrorIs(t, pip.UninstallGroup("test"), commands.ErrUnknownGroup)
+}
+
+func TestUninstallLeavesGroups(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.Equal(t, map[string][]string{"main": {"jwt"}}, pip.Groups())
+
+	assert.NoError(t, pip.Uninstall("jwt"))
+	assert.Empty(t, pip.Groups())
+}
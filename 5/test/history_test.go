@@ -0,0 +1,135 @@
+package main
+
+import (
+	"pip/commands"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("testify"))
+	assert.Error(t, pip.Install("invalid-dep"))
+	assert.NoError(t, pip.Uninstall("testify"))
+
+	history := pip.History()
+	assert.Len(t, history, 3)
+
+	assert.Equal(t, commands.OpInstall, history[0].Kind)
+	assert.Equal(t, []string{"testify"}, history[0].Packages)
+	assert.Equal(t, map[string]string{"testify": "1.8.2", "go-spew": "", "go-difflib": ""}, history[0].Installed)
+	assert.NoError(t, history[0].Err)
+	assert.False(t, history[0].Time.IsZero())
+	assert.NotEmpty(t, history[0].User)
+
+	assert.ErrorIs(t, history[1].Err, commands.ErrPackageNotFound)
+	assert.Empty(t, history[1].Installed)
+
+	assert.Equal(t, commands.OpUninstall, history[2].Kind)
+	assert.Equal(t, map[string]string{"testify": "1.8.2", "go-spew": "", "go-difflib": ""}, history[2].Removed)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	pip.SetHistoryLimit(2)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.NoError(t, pip.Install("go-spew"))
+	assert.NoError(t, pip.Install("bytebufferpool"))
+
+	history := pip.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, []string{"go-spew"}, history[0].Packages)
+
+	// installed packages stay searchable after their entry is dropped
+	assert.Equal(t, []string{"jwt"}, pip.LocalSearch("jwt"))
+}
+
+func TestUndoInstall(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.NoError(t, pip.Install("testify"))
+
+	assert.NoError(t, pip.Undo(1))
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllInstalledPackages())
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllUserInstalledPackages())
+	assert.NoError(t, pip.Check())
+
+	history := pip.History()
+	assert.True(t, history[1].Undone)
+	assert.Equal(t, commands.OpUndo, history[2].Kind)
+	assert.Len(t, history[2].Removed, 3)
+
+	// an undo is not undone again, the next operation back is
+	assert.NoError(t, pip.Undo(1))
+	assert.Empty(t, pip.AllInstalledPackages())
+	assert.ErrorIs(t, pip.Undo(1), commands.ErrNothingToUndo)
+}
+
+func TestUndoUninstall(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.InstallGroups(groupedProject(), commands.GroupOptions{}))
+	assert.NoError(t, pip.UninstallGroup("test"))
+	assert.NoError(t, pip.UninstallGroup("dev"))
+	assert.ElementsMatch(t, []string{"jwt"}, pip.AllInstalledPackages())
+
+	assert.NoError(t, pip.Undo(2))
+	assert.ElementsMatch(t,
+		[]string{"jwt", "testify", "go-spew", "go-difflib", "fasttemplate", "bytebufferpool"},
+		pip.AllInstalledPackages(),
+	)
+	assert.ElementsMatch(t, []string{"jwt", "testify", "fasttemplate"}, pip.AllUserInstalledPackages())
+	assert.Contains(t, pip.Groups(), "dev")
+	assert.NoError(t, pip.Check())
+	assert.Empty(t, pip.Verify())
+}
+
+func TestUndoEditable(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	project := localProject(t)
+	assert.NoError(t, pip.InstallEditable(project))
+	assert.NoError(t, pip.Uninstall("my-app"))
+
+	assert.NoError(t, pip.Undo(1))
+	assert.True(t, pip.IsEditable("my-app"))
+	assert.NoError(t, project.WriteToFile("src/main.go", "package app"))
+	content, err := dir.CatFile("my-app/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package app", content)
+}
+
+func TestUndoFix(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.NoError(t, dir.WriteToFile("jwt/src/main.go", "package evil"))
+	assert.NoError(t, pip.Fix())
+	assert.Empty(t, pip.Verify())
+
+	history := pip.History()
+	assert.Equal(t, commands.OpFix, history[1].Kind)
+	assert.Empty(t, history[1].Installed)
+	assert.Empty(t, history[1].Removed)
+
+	assert.NoError(t, pip.Undo(1))
+	assert.Equal(t, []string{"jwt"}, pip.AllInstalledPackages())
+	assert.Equal(t, []string{"jwt"}, pip.AllUserInstalledPackages())
+	assert.NoError(t, pip.Check())
+}
+
+func TestLocalSearchSkipsFailed(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.Error(t, pip.Install("invalid-dep"))
+	assert.Error(t, pip.Install("prj-with-invalid-dep"))
+	assert.Error(t, pip.UninstallForce("zzz-bogus"))
+	assert.Len(t, pip.History(), 4)
+
+	assert.Empty(t, pip.LocalSearch("invalid"))
+	assert.Empty(t, pip.LocalSearch("zzz"))
+	assert.Equal(t, []string{"jwt"}, pip.OnceInstalledPackages())
+
+	assert.NoError(t, pip.Uninstall("jwt"))
+	assert.Equal(t, []string{"jwt"}, pip.LocalSearch("jw"))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hookedGOPI(t testing.TB, hooks string) *LocalGOPI {
+	return &LocalGOPI{
+		data: map[string]*fs.Dir{
+			// hooks run commands, so the plugin has to be on disk
+			"plugin": withManifest(generateProjectIn(tempDir(t), "jwt"), `
+name = "plugin"
+version = "1.0.0"
+
+[hooks]
+`+hooks),
+			"jwt": generateProject(),
+		},
+	}
+}
+
+func TestHooksPostInstall(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `
+pre-install = "echo staged > staged.txt"
+post-install = "echo generated > generated.txt && pwd > where.txt"
+`))
+	err := pip.Install("plugin")
+	assert.NoError(t, err)
+
+	content, err := dir.CatFile("plugin/generated.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "generated\n", content)
+	content, err = dir.CatFile("plugin/staged.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "staged\n", content)
+}
+
+func TestHooksPreInstallInInstallDir(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `pre-install = "pwd > where.txt"`))
+	assert.NoError(t, pip.Install("plugin"))
+
+	content, err := dir.CatFile("plugin/where.txt")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(content, dir.Path()+"/"), content)
+	// nothing is left staged
+	dirs, err := dir.ListDirsIn("")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"jwt", "jwt/src", "plugin", "plugin/src"}, dirs)
+}
+
+func TestHooksConfined(t *testing.T) {
+	outside := tempDir(t)
+	for _, stage := range []string{"pre-install", "post-install"} {
+		dir := tempDir(t)
+		registry := hookedGOPI(t, stage+` = "echo escaped > `+outside.Path()+`/escaped.txt"`)
+		pip := commands.NewPIP(dir, registry)
+		err := pip.Install("plugin")
+		assert.ErrorIs(t, err, commands.ErrHookFailed)
+		assert.Empty(t, outside.ListFilesRoot())
+		assert.Empty(t, dir.ListFilesRoot())
+
+		// the registry's copy is out of reach too
+		registry = hookedGOPI(t, stage+` = "echo changed >> `+registry.data["plugin"].Path()+`/requirements.txt"`)
+		pip = commands.NewPIP(dir, registry)
+		assert.ErrorIs(t, pip.Install("plugin"), commands.ErrHookFailed)
+		content, err := registry.data["plugin"].CatFile("requirements.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "jwt\n", content)
+	}
+}
+
+func TestHooksFailureRollsBack(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `post-install = "exit 3"`))
+	err := pip.Install("jwt", "plugin")
+	assert.ErrorIs(t, err, commands.ErrHookFailed)
+	assert.Empty(t, pip.AllInstalledPackages())
+	assert.Empty(t, pip.AllUserInstalledPackages())
+	assert.Empty(t, dir.ListFilesRoot())
+}
+
+func TestHooksTimeout(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `pre-install = "sleep 5"`))
+	pip.SetHookTimeout(100 * time.Millisecond)
+	start := time.Now()
+	err := pip.Install("plugin")
+	assert.ErrorIs(t, err, commands.ErrHookFailed)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestHooksDisabled(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `
+post-install = "exit 1"
+pre-uninstall = "exit 1"
+`))
+	// hooks are on by default
+	assert.ErrorIs(t, pip.Install("plugin"), commands.ErrHookFailed)
+
+	pip.SetHooksEnabled(false)
+	assert.NoError(t, pip.Install("plugin"))
+	assert.NoError(t, pip.Uninstall("plugin"))
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestHooksPreUninstall(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, hookedGOPI(t, `pre-uninstall = "test -f keep && exit 1 || exit 0"`))
+	assert.NoError(t, pip.Install("plugin"))
+
+	assert.NoError(t, dir.CreateFile("plugin/keep"))
+	err := pip.Uninstall("plugin")
+	assert.ErrorIs(t, err, commands.ErrHookFailed)
+	assert.Contains(t, pip.AllInstalledPackages(), "plugin")
+
+	assert.NoError(t, dir.Remove("plugin/keep"))
+	assert.NoError(t, pip.Uninstall("plugin"))
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestHooksHardlinkedInstall(t *testing.T) {
+	registry := hookedGOPI(t, `post-install = "echo changed >> src/main.go && chmod +x src/main.go"`)
+	src := registry.data["plugin"]
+	src.SetCopyStrategy(fs.CopyHardlink)
+	dir := tempDir(t)
+	dir.SetCopyStrategy(fs.CopyHardlink)
+	pip := commands.NewPIP(dir, registry)
+	assert.NoError(t, pip.Install("plugin"))
+
+	content, err := dir.CatFile("plugin/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "// TODO: implementchanged\n", content)
+	content, err = src.CatFile("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "// TODO: implement", content)
+	info, err := src.Stat("src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, 0644, int(info.Mode().Perm()))
+}
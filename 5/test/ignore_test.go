@@ -0,0 +1,133 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withTree adds empty files to dir, making their parents.
+func withTree(t *testing.T, dir *fs.Dir, files ...string) *fs.Dir {
+	t.Helper()
+	for _, file := range files {
+		parts := strings.Split(file, "/")
+		for i := 1; i < len(parts); i++ {
+			dir.CreateDir(strings.Join(parts[:i], "/"))
+		}
+		assert.NoError(t, dir.WriteBytes(file, nil))
+	}
+	return dir
+}
+
+func ignoredProject(t *testing.T, dir *fs.Dir) *fs.Dir {
+	generateProjectIn(dir, "jwt")
+	withTree(t, dir,
+		"build/out.bin",
+		"src/build/gen.go",
+		"src/util.go",
+		"src/util_test.go",
+		"docs/api/index.md",
+		".git/HEAD",
+		"logs/keep.log",
+		"logs/debug.log",
+		"cache/keep.log",
+	)
+	return withFile(dir, fs.IgnoreFile, `# artifacts
+/build/
+.git
+*_test.go
+logs/*.log
+!logs/keep.log
+cache/
+!cache/keep.log
+`)
+}
+
+var kept = []string{
+	".gopiignore",
+	"docs/api/index.md",
+	"logs/keep.log",
+	"requirements.txt",
+	"src/build/gen.go",
+	"src/main.go",
+	"src/util.go",
+}
+
+func TestIgnoreListing(t *testing.T) {
+	for _, dir := range []*fs.Dir{fs.MemDir(), tempDir(t)} {
+		ignoredProject(t, dir)
+		assert.ElementsMatch(t, kept, dir.ListFilesRoot())
+		files, err := dir.ListFilesIn("src")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"src/build/gen.go", "src/main.go", "src/util.go"}, files)
+		dirs, err := dir.ListDirsIn("")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"docs", "docs/api", "logs", "src", "src/build"}, dirs)
+
+		// ignored files can still be read and written
+		assert.NoError(t, dir.WriteToFile("build/out.bin", "x"))
+		content, err := dir.CatFile("build/out.bin")
+		assert.NoError(t, err)
+		assert.Equal(t, "x", content)
+	}
+}
+
+func TestIgnoreCopies(t *testing.T) {
+	for _, strategy := range []fs.CopyStrategy{fs.CopyDeep, fs.CopyHardlink} {
+		src := ignoredProject(t, tempDir(t))
+		src.SetCopyStrategy(strategy)
+		clone := src.Clone()
+		defer clone.Close()
+		assert.ElementsMatch(t, kept, clone.ListFilesRoot())
+		_, err := clone.Stat("build")
+		assert.Error(t, err)
+	}
+
+	mem := ignoredProject(t, fs.MemDir())
+	assert.ElementsMatch(t, kept, mem.Clone().ListFilesRoot())
+	_, err := mem.Clone().Stat(".git/HEAD")
+	assert.Error(t, err)
+
+	disk := tempDir(t)
+	assert.NoError(t, disk.Mount("pkg", mem))
+	files, err := disk.ListFilesIn("pkg")
+	assert.NoError(t, err)
+	assert.Len(t, files, len(kept))
+	_, err = disk.Stat("pkg/build/out.bin")
+	assert.Error(t, err)
+}
+
+func TestIgnorePack(t *testing.T) {
+	data, err := commands.Pack(ignoredProject(t, fs.MemDir()))
+	assert.NoError(t, err)
+	unpacked := fs.MemDir()
+	assert.NoError(t, commands.UnpackTo(unpacked, data))
+	assert.ElementsMatch(t, kept, unpacked.ListFilesRoot())
+}
+
+func TestGlob(t *testing.T) {
+	dir := ignoredProject(t, fs.MemDir())
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.txt", []string{"requirements.txt"}},
+		{"src/*.go", []string{"src/main.go", "src/util.go"}},
+		{"**/*.go", []string{"src/build/gen.go", "src/main.go", "src/util.go"}},
+		{"**/index.md", []string{"docs/api/index.md"}},
+		{"docs/**", []string{"docs/api/index.md"}},
+		{"src/**/gen.go", []string{"src/build/gen.go"}},
+		{"**/*.bin", []string{}},
+		{"*.md", []string{}},
+	}
+	for _, c := range cases {
+		matches, err := dir.Glob(c.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, matches, c.pattern)
+	}
+	_, err := dir.Glob("src/[")
+	assert.Error(t, err)
+}
@@ -9,9 +9,10 @@ import (
 )
 
 var (
-	dir  *fs.Dir
-	gopi commands.GOPI
-	pip  *commands.PIP
+	dir    *fs.Dir
+	gopi   commands.GOPI
+	pip    *commands.PIP
+	pipDir *fs.Dir
 )
 
 type LocalGOPI struct {
@@ -21,20 +22,55 @@ type LocalGOPI struct {
 func (gopi *LocalGOPI) Get(pkgName string) (*fs.Dir, error) {
 	dir, prs := gopi.data[pkgName]
 	if !prs {
-		return nil, fmt.Errorf("404: package %s cannot found in GOPI", pkgName)
+		return nil, fmt.Errorf("404: package %s cannot found in GOPI: %w", pkgName, commands.ErrPackageNotFound)
 	}
 
 	return dir.Clone(), nil
 }
 
+func (gopi *LocalGOPI) Put(archive *commands.Archive) error {
+	dir := fs.MemDir()
+	if err := commands.UnpackTo(dir, archive.Data); err != nil {
+		return err
+	}
+	gopi.data[archive.Metadata.Name] = dir
+	return nil
+}
+
+func (gopi *LocalGOPI) Index() ([]commands.PackageMetadata, error) {
+	result := make([]commands.PackageMetadata, 0, len(gopi.data))
+	for name, dir := range gopi.data {
+		meta, err := commands.ReadMetadata(dir)
+		if err != nil {
+			meta = &commands.PackageMetadata{Name: name}
+		}
+		result = append(result, *meta)
+	}
+	return result, nil
+}
+
 func TestMain(m *testing.M) {
 	Setup()
-	os.Exit(m.Run())
+	code := m.Run()
+	dir.Close()
+	pipDir.Close()
+	os.Exit(code)
+}
+
+// tempDir returns a temporary dir on disk that is removed after the test.
+func tempDir(t testing.TB) *fs.Dir {
+	dir := fs.MkDir()
+	t.Cleanup(func() { dir.Close() })
+	return dir
 }
 
+// generateProject builds a project in memory.
 func generateProject(deps ...string) *fs.Dir {
+	return generateProjectIn(fs.MemDir(), deps...)
+}
+
+func generateProjectIn(dir *fs.Dir, deps ...string) *fs.Dir {
 	req := "requirements.txt"
-	dir := fs.MkDir()
 	dir.CreateFile(req)
 	for _, dep := range deps {
 		dir.AppendToFile(req, fmt.Sprintf("%s\n", dep))
@@ -47,6 +83,18 @@ func generateProject(deps ...string) *fs.Dir {
 	return dir
 }
 
+func withManifest(dir *fs.Dir, manifest string) *fs.Dir {
+	dir.CreateFile(commands.ManifestFile)
+	dir.WriteToFile(commands.ManifestFile, manifest)
+	return dir
+}
+
+func withFile(dir *fs.Dir, file, content string) *fs.Dir {
+	dir.CreateFile(file)
+	dir.WriteToFile(file, content)
+	return dir
+}
+
 func Setup() {
 	dir = fs.MkDir()
 
@@ -87,17 +135,56 @@ func Setup() {
 
 	gopi = &LocalGOPI{
 		data: map[string]*fs.Dir{
-			"echo":                          generateProject("jwt", "testify", "fasttemplate"),
-			"jwt":                           generateProject(),
-			"testify":                       generateProject("go-spew", "go-difflib"),
-			"fasttemplate":                  generateProject("bytebufferpool"),
+			"echo": withManifest(generateProject("jwt", "testify", "fasttemplate"), `
+name = "echo"
+version = "4.11.0"
+description = "High performance, minimalist web framework"
+license = "MIT"
+authors = ["LabStack"]
+keywords = ["web", "http", "router"]
+`),
+			"jwt": withManifest(generateProject(), `
+name = "jwt"
+version = "5.0.0"
+description = "JSON Web Tokens for authentication"
+license = "MIT"
+keywords = ["auth", "token"]
+`),
+			"testify": withManifest(generateProject("go-spew", "go-difflib"), `
+name = "testify"
+version = "1.8.2"
+description = "Toolkit with common assertions and mocks"
+license = "MIT"
+keywords = ["testing", "assert"]
+`),
+			"fasttemplate": withManifest(generateProject("bytebufferpool"), `
+name = "fasttemplate"
+version = "1.2.2"
+description = "Simple and fast template engine"
+keywords = ["template"]
+`),
 			"go-spew":                       generateProject(),
 			"bytebufferpool":                generateProject(),
 			"go-difflib":                    generateProject(),
 			"prj-with-indirect-invalid-dep": generateProject("prj-with-invalid-dep"),
 			"prj-with-invalid-dep":          generateProject("invalid-dep"),
+			"needs-jwt":                     generateProject("jwt>=4.0"),
+			"needs-new-jwt":                 generateProject("jwt>=9.0.0"),
+			"gpl-lib": withFile(generateProject(), "LICENSE", `
+                    GNU GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+ Copyright (C) 2007 Free Software Foundation, Inc.
+`),
+			"apache-lib": withFile(generateProject("gpl-lib"), "LICENSE.txt", `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/
+`),
+			"prj-with-bad-manifest": withManifest(generateProject(), `name = "other-name"`),
 		},
 	}
 
-	pip = commands.NewPIP(fs.MkDir(), gopi)
+	pipDir = fs.MkDir()
+	pip = commands.NewPIP(pipDir, gopi)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"pip/commands"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify1(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("echo"))
+	assert.Empty(t, pip.Verify())
+
+	assert.NoError(t, dir.AppendToFile("jwt/src/main.go", "\nfunc backdoor() {}\n"))
+	assert.NoError(t, dir.Remove("testify/requirements.txt"))
+	assert.NoError(t, dir.CreateFile("testify/evil.go"))
+
+	reports := pip.Verify()
+	assert.Len(t, reports, 2)
+	for _, report := range reports {
+		switch report.Package {
+		case "jwt":
+			assert.Equal(t, []string{"src/main.go"}, report.Modified)
+			assert.Empty(t, report.Missing)
+			assert.Empty(t, report.Extra)
+		case "testify":
+			assert.Equal(t, []string{"requirements.txt"}, report.Missing)
+			assert.Equal(t, []string{"evil.go"}, report.Extra)
+		default:
+			t.Errorf("unexpected report for %s", report.Package)
+		}
+	}
+}
+
+func TestVerifyMissingPackageDir(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	assert.NoError(t, dir.Remove("jwt"))
+
+	reports := pip.Verify()
+	assert.Len(t, reports, 1)
+	assert.ElementsMatch(t, []string{commands.ManifestFile, "requirements.txt", "src/main.go"}, reports[0].Missing)
+}
+
+func TestFixReinstallsCorrupted(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("echo"))
+	assert.NoError(t, dir.WriteToFile("jwt/src/main.go", "package evil"))
+	assert.NoError(t, dir.Remove("go-spew"))
+
+	assert.NoError(t, pip.Fix())
+	assert.Empty(t, pip.Verify())
+	content, err := dir.CatFile("jwt/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "// TODO: implement", content)
+	assert.NoError(t, pip.Check())
+	assert.Equal(t, []string{"echo"}, pip.AllUserInstalledPackages())
+}
+
+func TestFixKeepsPackageOnFailure(t *testing.T) {
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, gopi)
+	assert.NoError(t, pip.Install("jwt"))
+	pub, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	pip.TrustKey(pub)
+	assert.NoError(t, dir.WriteToFile("jwt/src/main.go", "package evil"))
+
+	// the registry's copy is unsigned, so the tampered one stays
+	assert.ErrorIs(t, pip.Fix(), commands.ErrUnsigned)
+	assert.Equal(t, []string{"jwt"}, pip.AllInstalledPackages())
+	assert.Equal(t, []string{"jwt"}, pip.AllUserInstalledPackages())
+	content, err := dir.CatFile("jwt/src/main.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package evil", content)
+	dirs, err := dir.ListDirsIn("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jwt", "jwt/src"}, dirs)
+	reports := pip.Verify()
+	assert.Len(t, reports, 1)
+	assert.Equal(t, []string{"src/main.go"}, reports[0].Modified)
+
+	pip.AllowUnsigned("jwt")
+	assert.NoError(t, pip.Fix())
+	assert.Empty(t, pip.Verify())
+	dirs, err = dir.ListDirsIn("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jwt", "jwt/src"}, dirs)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"pip/commands"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func licenseOf(licenses []commands.LicenseInfo, pkg string) string {
+	for _, info := range licenses {
+		if info.Package == pkg {
+			return info.License
+		}
+	}
+	return ""
+}
+
+func TestDetectLicense(t *testing.T) {
+	assert.Equal(t, "MPL-2.0", commands.DetectLicense("// SPDX-License-Identifier: MPL-2.0\n"))
+	assert.Equal(t, commands.NoAssertion, commands.DetectLicense("all rights reserved"))
+}
+
+// TestDetectLicenseTexts runs detection on the full license texts, whose
+// bodies mention other licenses.
+func TestDetectLicenseTexts(t *testing.T) {
+	files, err := filepath.Glob("testdata/licenses/*.txt")
+	assert.NoError(t, err)
+	assert.Len(t, files, 12)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		id := strings.TrimSuffix(filepath.Base(file), ".txt")
+		assert.Equal(t, id, commands.DetectLicense(string(content)), file)
+		// a copyright line above the title does not matter
+		assert.Equal(t, id, commands.DetectLicense("Copyright (c) 2024 The Authors\n\n"+string(content)), file)
+	}
+}
+
+func TestLicensePolicyMPL(t *testing.T) {
+	content, err := os.ReadFile("testdata/licenses/MPL-2.0.txt")
+	assert.NoError(t, err)
+	policy := commands.LicensePolicy{Deny: []string{"AGPL-3.0"}}
+	assert.False(t, policy.Denied(commands.DetectLicense(string(content))))
+}
+
+func TestLicenses1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.Install("echo", "apache-lib")
+	assert.NoError(t, err)
+
+	licenses, err := pip.Licenses()
+	assert.NoError(t, err)
+	assert.Len(t, licenses, len(pip.AllInstalledPackages()))
+	assert.Equal(t, "MIT", licenseOf(licenses, "echo"))
+	assert.Equal(t, "Apache-2.0", licenseOf(licenses, "apache-lib"))
+	assert.Equal(t, "GPL-3.0", licenseOf(licenses, "gpl-lib"))
+	assert.Equal(t, commands.NoAssertion, licenseOf(licenses, "go-spew"))
+}
+
+func TestLicensePolicy1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.Install("echo")
+	assert.NoError(t, err)
+	pip.SetLicensePolicy(commands.LicensePolicy{Deny: []string{"GPL-3.0"}})
+	assert.NoError(t, pip.CheckLicenses())
+
+	err = pip.Install("apache-lib")
+	assert.NoError(t, err)
+	err = pip.CheckLicenses()
+	assert.ErrorIs(t, err, commands.ErrLicenseDenied)
+	assert.Contains(t, err.Error(), "gpl-lib")
+	assert.NotContains(t, err.Error(), "apache-lib")
+}
+
+func TestLicensePolicy2(t *testing.T) {
+	policy := commands.LicensePolicy{Deny: []string{"gpl-3.0", commands.NoAssertion}}
+	assert.True(t, policy.Denied("GPL-3.0"))
+	assert.True(t, policy.Denied(commands.NoAssertion))
+	assert.False(t, policy.Denied("MIT OR GPL-3.0"))
+	assert.True(t, policy.Denied("MIT AND GPL-3.0"))
+}
+
+func TestLicensePolicyExpressions(t *testing.T) {
+	policy := commands.LicensePolicy{Deny: []string{"Apache-2.0", "GPL-2.0"}}
+	for expr, denied := range map[string]bool{
+		"(MIT OR GPL-3.0) AND Apache-2.0":             true,
+		"(MIT OR Apache-2.0) AND BSD-3-Clause":        false,
+		"MIT OR GPL-3.0 AND Apache-2.0":               false,
+		"(MIT OR GPL-2.0) AND (Apache-2.0 OR ISC)":    false,
+		"GPL-2.0 WITH Classpath-exception-2.0":        true,
+		"MIT OR GPL-2.0 WITH Classpath-exception-2.0": false,
+		"((GPL-2.0))":        true,
+		"GPL-2.0-only":       true,
+		"GPL-2.0-or-later":   true,
+		"GPL-2.0+":           true,
+		"LGPL-2.0":           false,
+		"mit and apache-2.0": true,
+		// broken expressions are denied when they name a denied license
+		"(MIT AND Apache-2.0": true,
+		"MIT OR":              false,
+	} {
+		assert.Equal(t, denied, policy.Denied(expr), expr)
+	}
+
+	// detection gives the deprecated ids, policies may use the new ones
+	policy = commands.LicensePolicy{Deny: []string{"GPL-3.0-only"}}
+	assert.True(t, policy.Denied("GPL-3.0"))
+	assert.True(t, policy.Denied("GPL-3.0-or-later"))
+	assert.False(t, policy.Denied("LGPL-3.0"))
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetadata(t *testing.T) {
+	meta, err := commands.ParseMetadata(`
+name = "echo"
+version = "4.11.0"
+license = "MIT"
+authors = ["LabStack"]
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, meta.Validate())
+	assert.Equal(t, "echo", meta.Name)
+	assert.Equal(t, "4.11.0", meta.Version)
+	assert.Equal(t, []string{"LabStack"}, meta.Authors)
+}
+
+func TestParseMetadataInvalid(t *testing.T) {
+	_, err := commands.ParseMetadata(`name = `)
+	assert.Error(t, err)
+
+	_, err = commands.ParseMetadata(`homepage = "x"`)
+	assert.Error(t, err)
+
+	meta, err := commands.ParseMetadata(`version = "1.0"`)
+	assert.NoError(t, err)
+	assert.Error(t, meta.Validate())
+
+	meta, err = commands.ParseMetadata("name = \"x\"\nversion = \"one\"")
+	assert.NoError(t, err)
+	assert.Error(t, meta.Validate())
+}
+
+func TestShow1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	meta, err := pip.Show("echo")
+	assert.NoError(t, err)
+	assert.Equal(t, "4.11.0", meta.Version)
+	assert.Equal(t, "MIT", meta.License)
+
+	err = pip.Install("echo")
+	assert.NoError(t, err)
+	meta, err = pip.Show("testify")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.8.2", meta.Version)
+}
+
+func TestShow2(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	meta, err := pip.Show("go-spew")
+	assert.NoError(t, err)
+	assert.Equal(t, "go-spew", meta.Name)
+	assert.Empty(t, meta.Version)
+
+	_, err = pip.Show("numpy")
+	assert.Error(t, err)
+}
+
+func TestInstallBadManifest(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.Install("prj-with-bad-manifest")
+	assert.Error(t, err)
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestInstallVersion1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.Install("needs-jwt")
+	assert.NoError(t, err)
+	assert.Contains(t, pip.AllInstalledPackages(), "jwt")
+
+	deps, err := pip.DirectDeps("needs-jwt")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jwt"}, deps)
+}
+
+func TestInstallVersion2(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	err := pip.Install("needs-new-jwt")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "jwt>=9.0.0")
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestInstallVersion3(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("echo==4.11.0"))
+	assert.Equal(t, []string{"echo"}, pip.AllUserInstalledPackages())
+
+	pip = commands.NewPIP(tempDir(t), gopi)
+	assert.Error(t, pip.Install("echo<4"))
+	assert.Error(t, pip.Install("go-spew>=1.0"))
+	assert.Error(t, pip.Install("echo=="))
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func jwtVersion(version string) *fs.Dir {
+	return withManifest(generateProject(), `
+name = "jwt"
+version = "`+version+`"
+`)
+}
+
+func TestInstallVersionInstalled(t *testing.T) {
+	registry := &LocalGOPI{data: map[string]*fs.Dir{
+		"jwt": jwtVersion("1.0.0"),
+		"app": generateProject("jwt>=2.0"),
+	}}
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, registry)
+	assert.NoError(t, pip.Install("jwt"))
+
+	// the registry moved on, but the installed jwt is what app would get
+	registry.data["jwt"] = jwtVersion("2.0.0")
+	err := pip.Install("app")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `jwt>=2.0 is required but version "1.0.0" is installed`)
+	assert.Equal(t, []string{"jwt"}, pip.AllInstalledPackages())
+	assert.Error(t, pip.Install("jwt>=2.0"))
+
+	meta, err := pip.Show("jwt")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", meta.Version)
+	assert.NoError(t, pip.Check())
+
+	// a fresh install gets the new jwt
+	pip = commands.NewPIP(tempDir(t), registry)
+	assert.NoError(t, pip.Install("app"))
+	assert.NoError(t, pip.Check())
+}
+
+func TestCheckVersions(t *testing.T) {
+	registry := &LocalGOPI{data: map[string]*fs.Dir{
+		"jwt": jwtVersion("1.0.0"),
+		"app": generateProject("jwt>=1.0"),
+	}}
+	dir := tempDir(t)
+	pip := commands.NewPIP(dir, registry)
+	assert.NoError(t, pip.Install("app"))
+	assert.NoError(t, pip.Check())
+
+	assert.NoError(t, dir.WriteToFile("app/requirements.txt", "jwt>=2.0\n"))
+	err := pip.Check()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `app requires jwt>=2.0 but version "1.0.0" is installed`)
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, commands.CompareVersions("1.2", "1.2.0"))
+	assert.Equal(t, -1, commands.CompareVersions("1.2.0", "1.10.0"))
+	assert.Equal(t, 1, commands.CompareVersions("v2.0.0", "1.9.9"))
+}
+
+func TestRegistrySearchMetadata(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("router")
+	assert.NoError(t, err)
+	assert.Equal(t, "echo", result[0].Name)
+	assert.Equal(t, "4.11.0", result[0].Version)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertOutsideRoot(t *testing.T, err error) {
+	t.Helper()
+	var outside *fs.ErrOutsideRoot
+	assert.ErrorAs(t, err, &outside)
+}
+
+func TestPathTraversal(t *testing.T) {
+	for _, dir := range []*fs.Dir{tempDir(t), fs.MemDir()} {
+		generateProjectIn(dir, "jwt")
+
+		assertOutsideRoot(t, dir.Remove("../../etc"))
+		assertOutsideRoot(t, dir.Remove(".."))
+		assertOutsideRoot(t, dir.CreateFile("/tmp/evil"))
+		assertOutsideRoot(t, dir.CreateDir("src/../../evil"))
+		assertOutsideRoot(t, dir.Mount("../evil", generateProject()))
+		_, err := dir.CatFile("../../etc/passwd")
+		assertOutsideRoot(t, err)
+		_, err = dir.ListFilesIn("..")
+		assertOutsideRoot(t, err)
+
+		// ".." that stays inside the root is fine
+		content, err := dir.CatFile("src/../requirements.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "jwt\n", content)
+	}
+}
+
+func TestSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("s3cret"), 0644))
+	root := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "src"), 0755))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(root, "evil")))
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling")))
+	assert.NoError(t, os.Symlink("src", filepath.Join(root, "inner")))
+	dir := fs.NewDir(fs.NewOS(root))
+
+	_, err := dir.CatFile("evil/secret")
+	assertOutsideRoot(t, err)
+	assertOutsideRoot(t, dir.CreateFile("evil/new"))
+	assertOutsideRoot(t, dir.CreateFile("dangling"))
+	assertOutsideRoot(t, dir.Remove("evil/secret"))
+	assert.NoFileExists(t, filepath.Join(outside, "new"))
+	assert.NoFileExists(t, filepath.Join(outside, "missing"))
+
+	// links inside the root are followed
+	assert.NoError(t, dir.CreateFile("inner/main.go"))
+	assert.Contains(t, dir.ListFilesRoot(), "src/main.go")
+
+	// removing a link leaves its target alone
+	assert.NoError(t, dir.Remove("evil"))
+	assert.FileExists(t, filepath.Join(outside, "secret"))
+}
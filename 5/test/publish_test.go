@@ -0,0 +1,119 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRegistry() *LocalGOPI {
+	return &LocalGOPI{
+		data: map[string]*fs.Dir{
+			"jwt": generateProject(),
+		},
+	}
+}
+
+func myProject(version string) *fs.Dir {
+	return withManifest(generateProject("jwt"), `
+name = "my-lib"
+version = "`+version+`"
+license = "MIT"
+`)
+}
+
+func TestBuild1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), newRegistry())
+	archive, err := pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, "my-lib", archive.Metadata.Name)
+	assert.Equal(t, "0.1.0", archive.Metadata.Version)
+
+	dir, err := commands.Unpack(archive.Data)
+	assert.NoError(t, err)
+	defer dir.Close()
+	assert.ElementsMatch(t,
+		[]string{commands.ManifestFile, "requirements.txt", "src/main.go"},
+		dir.ListFilesRoot(),
+	)
+}
+
+func TestBuild2(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), newRegistry())
+
+	_, err := pip.Build(generateProject("jwt"))
+	assert.ErrorIs(t, err, commands.ErrInvalidPackage)
+
+	_, err = pip.Build(withManifest(generateProject(), `name = "my-lib"`))
+	assert.ErrorIs(t, err, commands.ErrInvalidPackage)
+
+	noReqs := withManifest(tempDir(t), "name = \"my-lib\"\nversion = \"1.0.0\"")
+	_, err = pip.Build(noReqs)
+	assert.ErrorIs(t, err, commands.ErrInvalidPackage)
+
+	badReqs := myProject("0.1.0")
+	badReqs.AppendToFile("requirements.txt", "jwt>=\n")
+	_, err = pip.Build(badReqs)
+	assert.ErrorIs(t, err, commands.ErrInvalidPackage)
+}
+
+func TestPublish1(t *testing.T) {
+	registry := newRegistry()
+	pip := commands.NewPIP(tempDir(t), registry)
+	archive, err := pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+	assert.NoError(t, pip.Publish(archive))
+
+	assert.NoError(t, pip.Install("my-lib"))
+	assert.ElementsMatch(t, []string{"my-lib", "jwt"}, pip.AllInstalledPackages())
+	meta, err := pip.Show("my-lib")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.0", meta.Version)
+}
+
+func TestPublish2(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), newRegistry())
+	archive, err := pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+	assert.NoError(t, pip.Publish(archive))
+	assert.ErrorIs(t, pip.Publish(archive), commands.ErrVersionExists)
+
+	archive, err = pip.Build(myProject("0.2.0"))
+	assert.NoError(t, err)
+	assert.NoError(t, pip.Publish(archive))
+	meta, err := pip.Show("my-lib")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.2.0", meta.Version)
+
+	// an older version does not replace the newest one
+	archive, err = pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pip.Publish(archive), commands.ErrVersionExists)
+	archive, err = pip.Build(myProject("0.2.0-rc.1"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pip.Publish(archive), commands.ErrVersionExists)
+	meta, err = pip.Show("my-lib")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.2.0", meta.Version)
+}
+
+func TestPublish3(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), newRegistry())
+	archive, err := pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+
+	archive.Metadata.Version = "0.3.0"
+	assert.ErrorIs(t, pip.Publish(archive), commands.ErrInvalidPackage)
+
+	err = pip.Publish(&commands.Archive{Metadata: archive.Metadata, Data: []byte("junk")})
+	assert.ErrorIs(t, err, commands.ErrInvalidPackage)
+}
+
+func TestPublishNotWritable(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), plainGOPI{})
+	archive, err := pip.Build(myProject("0.1.0"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, pip.Publish(archive), commands.ErrNotWritable)
+}
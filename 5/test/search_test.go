@@ -0,0 +1,123 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type plainGOPI struct{}
+
+func (plainGOPI) Get(pkgName string) (*fs.Dir, error) {
+	return gopi.Get(pkgName)
+}
+
+func resultNames(results []commands.SearchResult) []string {
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestRegistrySearch1(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("echo")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result)
+	assert.Equal(t, "echo", result[0].Name)
+}
+
+func TestRegistrySearch2(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("go")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"go-spew", "go-difflib"}, resultNames(result)[:2])
+}
+
+func TestRegistrySearch3(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("assert")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"testify"}, resultNames(result))
+}
+
+func TestRegistrySearch4(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("web framework")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"echo"}, resultNames(result))
+}
+
+func TestRegistrySearch5(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	result, err := pip.Search("tet")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"testify", "fasttemplate"}, resultNames(result)[:2])
+}
+
+func TestRegistrySearchPage(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	all, err := pip.Search("e")
+	assert.NoError(t, err)
+
+	page, total, err := pip.SearchPage("e", 2, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, len(all), total)
+	assert.Equal(t, all[2:4], page)
+
+	page, _, err = pip.SearchPage("e", 100, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	_, _, err = pip.SearchPage("e", 0, 2)
+	assert.Error(t, err)
+}
+
+func TestRegistrySearchNotSupported(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), plainGOPI{})
+	_, err := pip.Search("echo")
+	assert.ErrorIs(t, err, commands.ErrNotSearchable)
+}
+
+func searchPackage(name, keywords string) *fs.Dir {
+	return withManifest(generateProject(), `
+name = "`+name+`"
+version = "1.0.0"
+keywords = [`+keywords+`]
+`)
+}
+
+func TestRegistrySearchRanking(t *testing.T) {
+	registry := &LocalGOPI{data: map[string]*fs.Dir{
+		"jwt":                 searchPackage("jwt", `"auth", "token"`),
+		"go-token-server-kit": searchPackage("go-token-server-kit", `"server"`),
+		"jtoken":              searchPackage("jtoken", ""),
+	}}
+	pip := commands.NewPIP(tempDir(t), registry)
+	result, err := pip.Search("token")
+	assert.NoError(t, err)
+	// a close fuzzy name beats an exact keyword, a weak one does not
+	assert.Equal(t, []string{"jtoken", "jwt", "go-token-server-kit"}, resultNames(result))
+}
+
+func TestRegistrySearchIndexChanges(t *testing.T) {
+	registry := &LocalGOPI{data: map[string]*fs.Dir{
+		"jwt": searchPackage("jwt", `"auth"`),
+	}}
+	pip := commands.NewPIP(tempDir(t), registry)
+	result, err := pip.Search("token")
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+
+	registry.data["jwt"] = searchPackage("jwt", `"auth", "token"`)
+	registry.data["paseto"] = searchPackage("paseto", `"token"`)
+	for i := 0; i < 2; i++ {
+		page, total, err := pip.SearchPage("token", i+1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, []string{[]string{"jwt", "paseto"}[i]}, resultNames(page))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"pip/commands"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signedRegistry(t *testing.T, key ed25519.PrivateKey) *LocalGOPI {
+	registry := newRegistry()
+	publisher := commands.NewPIP(tempDir(t), registry)
+	publisher.SetSigningKey(key)
+	archive, err := publisher.Build(myProject("1.0.0"))
+	assert.NoError(t, err)
+	assert.NoError(t, publisher.Publish(archive))
+	return registry
+}
+
+func TestSigning1(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	pip := commands.NewPIP(tempDir(t), signedRegistry(t, priv))
+	pip.TrustKey(pub)
+
+	err = pip.Install("my-lib")
+	assert.ErrorIs(t, err, commands.ErrUnsigned)
+	assert.Empty(t, pip.AllInstalledPackages())
+
+	pip.AllowUnsigned("jwt")
+	assert.NoError(t, pip.Install("my-lib"))
+	assert.ElementsMatch(t, []string{"my-lib", "jwt"}, pip.AllInstalledPackages())
+}
+
+func TestSigning2(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	pip := commands.NewPIP(tempDir(t), signedRegistry(t, priv))
+	pip.TrustKey(other)
+	pip.AllowUnsigned("jwt")
+
+	err = pip.Install("my-lib")
+	assert.ErrorIs(t, err, commands.ErrBadSignature)
+	assert.Empty(t, pip.AllInstalledPackages())
+}
+
+func TestSigningTampered(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	registry := signedRegistry(t, priv)
+	registry.data["my-lib"].AppendToFile("src/main.go", "\nfunc init() { steal() }\n")
+
+	pip := commands.NewPIP(tempDir(t), registry)
+	pip.TrustKey(pub)
+	pip.AllowUnsigned("jwt")
+	assert.ErrorIs(t, pip.Install("my-lib"), commands.ErrBadSignature)
+
+	// allowing it unsigned does not let a bad signature through
+	pip.AllowUnsigned("my-lib")
+	assert.ErrorIs(t, pip.Install("my-lib"), commands.ErrBadSignature)
+	assert.Empty(t, pip.AllInstalledPackages())
+
+	assert.NoError(t, registry.data["my-lib"].Remove(commands.SignatureFile))
+	assert.NoError(t, pip.Install("my-lib"))
+}
+
+func TestSigningNoKeyring(t *testing.T) {
+	pip := commands.NewPIP(tempDir(t), gopi)
+	assert.NoError(t, pip.Install("jwt"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"pip/commands"
+	"pip/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage(t *testing.T) {
+	dir := withFile(generateProject("jwt"), fs.IgnoreFile, "build/\n")
+	assert.NoError(t, dir.CreateDir("build"))
+	assert.NoError(t, dir.WriteBytes("build/out.bin", make([]byte, 100)))
+
+	// ignored files take space too
+	usage, err := dir.Usage("")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, usage.Files)
+	assert.Equal(t, int64(len("jwt\n")+len("// TODO: implement")+len("build/\n")+100), usage.Bytes)
+	assert.Equal(t, int64(0), usage.Shared)
+	assert.Equal(t, usage.Bytes, usage.Disk)
+	assert.Equal(t, usage.Bytes, usage.Unique())
+
+	size, err := dir.Size("build/out.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+	count, err := dir.CountFiles("src")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	_, err = dir.Size("missing")
+	assert.Error(t, err)
+	_, err = dir.Size("../outside")
+	assert.Error(t, err)
+}
+
+func TestUsageHardlinks(t *testing.T) {
+	src := generateProjectIn(tempDir(t), "jwt")
+	dst := tempDir(t)
+	dst.SetCopyStrategy(fs.CopyHardlink)
+	assert.NoError(t, dst.Mount("a", src))
+	assert.NoError(t, dst.Mount("b", src))
+
+	a, err := dst.Usage("a")
+	assert.NoError(t, err)
+	assert.Equal(t, a.Bytes, a.Shared)
+	assert.Equal(t, int64(0), a.Unique())
+
+	// the files of a and b are the same on disk
+	total, err := dst.Usage("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*a.Bytes, total.Bytes)
+	assert.Equal(t, a.Bytes, total.Disk)
+}
+
+func TestSizes(t *testing.T) {
+	shared := generateProjectIn(tempDir(t))
+	shared.SetCopyStrategy(fs.CopyHardlink)
+	big := withFile(generateProject(), "data.txt", strings.Repeat("x", 1000))
+	registry := &LocalGOPI{data: map[string]*fs.Dir{
+		"a":       shared,
+		"b":       shared,
+		"big":     big,
+		"testify": generateProject(),
+	}}
+	dir := tempDir(t)
+	dir.SetCopyStrategy(fs.CopyHardlink)
+	pip := commands.NewPIP(dir, registry)
+	assert.NoError(t, pip.Install("a", "b", "big"))
+	assert.NoError(t, pip.InstallEditable(localProject(t)))
+
+	usage, err := pip.Sizes()
+	assert.NoError(t, err)
+	var names []string
+	for _, pkg := range usage.Packages {
+		names = append(names, pkg.Name)
+	}
+	assert.Equal(t, []string{"big", "a", "b", "testify", "my-app"}, names)
+
+	sizes := make(map[string]commands.PackageSize)
+	for _, pkg := range usage.Packages {
+		sizes[pkg.Name] = pkg
+	}
+	assert.Greater(t, sizes["big"].Bytes, int64(1000))
+	assert.Equal(t, int64(0), sizes["big"].Shared)
+	assert.Equal(t, sizes["a"].Bytes, sizes["a"].Shared)
+	assert.Equal(t, sizes["a"].Usage, sizes["b"].Usage)
+	assert.True(t, sizes["my-app"].Editable)
+	assert.Equal(t, 0, sizes["my-app"].Files)
+
+	// a and b are counted once on disk
+	assert.GreaterOrEqual(t, usage.Total.Bytes, sizes["big"].Bytes+2*sizes["a"].Bytes)
+	assert.Equal(t, usage.Total.Bytes-sizes["a"].Bytes, usage.Total.Disk)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"pip/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextBatch(t *testing.T, events <-chan []fs.Event) []fs.Event {
+	t.Helper()
+	select {
+	case batch := <-events:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("no events")
+		return nil
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, dir := range []*fs.Dir{tempDir(t), fs.MemDir()} {
+		generateProjectIn(dir, "jwt")
+		ctx, cancel := context.WithCancel(context.Background())
+		events := dir.Watch(ctx)
+
+		assert.NoError(t, dir.WriteToFile("requirements.txt", "jwt\ntestify\n"))
+		assert.NoError(t, dir.Remove("src/main.go"))
+		assert.NoError(t, dir.CreateDir("tests"))
+		assert.NoError(t, dir.WriteBytes("tests/main_test.go", []byte("package main\n")))
+		assert.Equal(t, []fs.Event{
+			{Path: "requirements.txt", Op: fs.Modified},
+			{Path: "src/main.go", Op: fs.Removed},
+			{Path: "tests/main_test.go", Op: fs.Created},
+		}, nextBatch(t, events))
+
+		// removing a dir removes the files in it
+		assert.NoError(t, dir.Remove("tests"))
+		assert.Equal(t, []fs.Event{{Path: "tests/main_test.go", Op: fs.Removed}}, nextBatch(t, events))
+
+		cancel()
+		for range events {
+		}
+	}
+}
+
+func TestWatchDebounce(t *testing.T) {
+	dir := generateProjectIn(tempDir(t), "jwt")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := dir.Watch(ctx)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, dir.AppendToFile("src/main.go", "\n"))
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, []fs.Event{{Path: "src/main.go", Op: fs.Modified}}, nextBatch(t, events))
+
+	// a file made and removed again is no change
+	assert.NoError(t, dir.WriteBytes("scratch", nil))
+	assert.NoError(t, dir.Remove("scratch"))
+	assert.NoError(t, dir.AppendToFile("requirements.txt", "echo\n"))
+	assert.Equal(t, []fs.Event{{Path: "requirements.txt", Op: fs.Modified}}, nextBatch(t, events))
+}
+
+func TestWatchIgnored(t *testing.T) {
+	for _, dir := range []*fs.Dir{tempDir(t), fs.MemDir()} {
+		withFile(generateProjectIn(dir, "jwt"), fs.IgnoreFile, "build/\n")
+		assert.NoError(t, dir.CreateDir("build"))
+		ctx, cancel := context.WithCancel(context.Background())
+		events := dir.Watch(ctx)
+		assert.NoError(t, dir.WriteBytes("build/out.bin", []byte{0}))
+		time.Sleep(400 * time.Millisecond)
+		assert.NoError(t, dir.WriteToFile("src/main.go", "package main\n"))
+		assert.Equal(t, []fs.Event{{Path: "src/main.go", Op: fs.Modified}}, nextBatch(t, events))
+		cancel()
+	}
+}
+
+// TestWatchImportCheck re-runs the import check of a source file whenever it
+// changes, as dev tooling would.
+func TestWatchImportCheck(t *testing.T) {
+	dir := withFile(tempDir(t), "main.go", "package main\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := dir.Watch(ctx)
+
+	assert.NoError(t, dir.WriteToFile("main.go", "package main\nimport \"not/installed\"\n"))
+	batch := nextBatch(t, events)
+	assert.Equal(t, "main.go", batch[0].Path)
+	src, err := dir.CatFile(batch[0].Path)
+	assert.NoError(t, err)
+	assert.Error(t, pip.ImportCheck(src))
+
+	assert.NoError(t, dir.WriteToFile("main.go", "package main\nimport \"fmt\"\n"))
+	batch = nextBatch(t, events)
+	src, err = dir.CatFile(batch[0].Path)
+	assert.NoError(t, err)
+	assert.NoError(t, pip.ImportCheck(src))
+}
+
+func TestWatchBusyFile(t *testing.T) {
+	dir := withFile(tempDir(t), "app.log", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := dir.Watch(ctx)
+
+	// the log never stays quiet for the debounce
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				dir.AppendToFile("app.log", "line\n")
+			}
+		}
+	}()
+	start := time.Now()
+	assert.Equal(t, []fs.Event{{Path: "app.log", Op: fs.Modified}}, nextBatch(t, events))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}